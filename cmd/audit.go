@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/google/uuid"
+	"github.com/takumakume/kev-to-dependencytrack/dependencytrack"
+	"github.com/takumakume/kev-to-dependencytrack/kev"
+)
+
+// audit is a finding whose vulnerability is in the KEV catalog.
+type audit struct {
+	project       uuid.UUID
+	finding       dtrack.Finding
+	vulnerability kev.Vulnerability
+
+	// recorded is set when an analysis was recorded for the finding
+	recorded *analysisResult
+}
+
+// auditFindings records the KEV entry as an analysis comment on the findings of
+// the projects whose vulnerability, or one of its aliases, is in the catalog.
+// The analysis state is raised to opts.auditAnalysisState when given.
+// A finding already having the comment and the state is left as is.
+func auditFindings(ctx context.Context, client dependencytrack.DependencyTrackClient, projectUUIDs []uuid.UUID, catalog *kev.Catalog, opts applyOptions) (recorded []analysisResult, err error) {
+	vulnerabilities := make(map[string]kev.Vulnerability)
+	for _, v := range catalog.Vulnerabilities {
+		vulnerabilities[v.CveID] = v
+	}
+
+	audits := []*audit{}
+	for _, p := range projectUUIDs {
+		findings, err := client.GetFindings(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("get findings of project %s: %w", p, err)
+		}
+		for _, f := range findings {
+			if v, ok := kevVulnerability(vulnerabilities, f.Vulnerability); ok {
+				audits = append(audits, &audit{project: p, finding: f, vulnerability: v})
+			}
+		}
+	}
+	log.Printf("audit findings: %d findings of %d projects are in the KEV catalog", len(audits), len(projectUUIDs))
+
+	_, err = runConcurrently(audits, opts.concurrency, func(a *audit) error {
+		return auditFinding(ctx, client, a, opts)
+	})
+
+	for _, a := range audits {
+		if a.recorded != nil {
+			recorded = append(recorded, *a.recorded)
+		}
+	}
+
+	return recorded, err
+}
+
+func auditFinding(ctx context.Context, client dependencytrack.DependencyTrackClient, a *audit, opts applyOptions) error {
+	component := a.finding.Component.UUID
+	vulnerability := a.finding.Vulnerability.UUID
+
+	analysis, err := client.GetAnalysis(ctx, component, a.project, vulnerability)
+	if err != nil && !dependencytrack.IsNotFound(err) {
+		return fmt.Errorf("get analysis of %s in %s: %w", a.finding.Vulnerability.VulnID, a.finding.Component.Name, err)
+	}
+
+	comment := auditComment(a.vulnerability)
+	state := auditState(analysis.State, dtrack.AnalysisState(opts.auditAnalysisState))
+	if hasComment(analysis, comment) && state == analysis.State {
+		return nil
+	}
+
+	log.Printf("audit findings: record analysis of %s in %s %s: %s", a.finding.Vulnerability.VulnID, a.finding.Component.Name, a.finding.Component.Version, state)
+
+	if !opts.dryRun {
+		req := dtrack.AnalysisRequest{
+			Component:     component,
+			Project:       a.project,
+			Vulnerability: vulnerability,
+			State:         state,
+		}
+		if !hasComment(analysis, comment) {
+			req.Comment = comment
+		}
+		if _, err := client.RecordAnalysis(ctx, req); err != nil {
+			return fmt.Errorf("record analysis of %s in %s: %w", a.finding.Vulnerability.VulnID, a.finding.Component.Name, err)
+		}
+	}
+
+	a.recorded = &analysisResult{
+		Project:   a.project,
+		Component: a.finding.Component.Name + ":" + a.finding.Component.Version,
+		VulnID:    a.finding.Vulnerability.VulnID,
+		State:     string(state),
+	}
+	return nil
+}
+
+// kevVulnerability returns the KEV entry of the vulnerability ID or of one of its CVE aliases.
+func kevVulnerability(vulnerabilities map[string]kev.Vulnerability, v dtrack.FindingVulnerability) (kev.Vulnerability, bool) {
+	if kv, ok := vulnerabilities[v.VulnID]; ok {
+		return kv, true
+	}
+	for _, alias := range v.Aliases {
+		if kv, ok := vulnerabilities[alias.CveID]; ok {
+			return kv, true
+		}
+	}
+	return kev.Vulnerability{}, false
+}
+
+func auditComment(v kev.Vulnerability) string {
+	return fmt.Sprintf("%s is in the CISA Known Exploited Vulnerabilities catalog.\nDate added: %s\nDue date: %s\nRequired action: %s",
+		v.CveID, v.DateAdded, v.DueDate, v.RequiredAction)
+}
+
+func hasComment(analysis dtrack.Analysis, comment string) bool {
+	for _, c := range analysis.Comments {
+		if c.Comment == comment {
+			return true
+		}
+	}
+	return false
+}
+
+// auditStateRanks orders the analysis states audit may change.
+// The other states are decided by an auditor and are never changed.
+var auditStateRanks = map[dtrack.AnalysisState]int{
+	"":                              0,
+	dtrack.AnalysisStateNotSet:      0,
+	dtrack.AnalysisStateInTriage:    1,
+	dtrack.AnalysisStateExploitable: 2,
+}
+
+// auditState returns the analysis state to record: target when it ranks
+// higher than current, otherwise current.
+func auditState(current, target dtrack.AnalysisState) dtrack.AnalysisState {
+	currentRank, ok := auditStateRanks[current]
+	if !ok || target == "" {
+		return current
+	}
+	if auditStateRanks[target] > currentRank {
+		return target
+	}
+	return current
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/takumakume/kev-to-dependencytrack/kev"
+	"github.com/takumakume/kev-to-dependencytrack/mock"
+)
+
+func Test_auditFindings(t *testing.T) {
+	projectUUID := uuid.New()
+	componentUUID := uuid.New()
+	vulnerabilityUUID := uuid.New()
+
+	kevVulnerability := kev.Vulnerability{
+		CveID:          "CVE-2023-0001",
+		DateAdded:      "2023-01-10",
+		DueDate:        "2023-01-31",
+		RequiredAction: "Apply updates per vendor instructions.",
+	}
+	catalog := &kev.Catalog{Vulnerabilities: []kev.Vulnerability{kevVulnerability}}
+	comment := auditComment(kevVulnerability)
+
+	finding := func(vulnID string, aliases ...string) dtrack.Finding {
+		f := dtrack.Finding{
+			Component:     dtrack.FindingComponent{UUID: componentUUID, Name: "log4j-core", Version: "2.14.1"},
+			Vulnerability: dtrack.FindingVulnerability{UUID: vulnerabilityUUID, VulnID: vulnID},
+		}
+		for _, a := range aliases {
+			f.Vulnerability.Aliases = append(f.Vulnerability.Aliases, dtrack.VulnerabilityAlias{CveID: a})
+		}
+		return f
+	}
+	recorded := func(state string) []analysisResult {
+		return []analysisResult{{Project: projectUUID, Component: "log4j-core:2.14.1", VulnID: "CVE-2023-0001", State: state}}
+	}
+
+	tests := []struct {
+		name       string
+		opts       applyOptions
+		finding    dtrack.Finding
+		analysis   dtrack.Analysis
+		wantReq    *dtrack.AnalysisRequest
+		want       []analysisResult
+		noAnalysis bool
+	}{
+		{
+			name:       "comment and raise the state of a finding without analysis",
+			opts:       applyOptions{auditAnalysisState: "EXPLOITABLE"},
+			finding:    finding("CVE-2023-0001"),
+			noAnalysis: true,
+			wantReq:    &dtrack.AnalysisRequest{Comment: comment, State: dtrack.AnalysisStateExploitable},
+			want:       recorded("EXPLOITABLE"),
+		},
+		{
+			name:       "comment only",
+			finding:    finding("CVE-2023-0001"),
+			noAnalysis: true,
+			wantReq:    &dtrack.AnalysisRequest{Comment: comment},
+			want:       recorded(""),
+		},
+		{
+			name:     "match an alias",
+			opts:     applyOptions{auditAnalysisState: "IN_TRIAGE"},
+			finding:  finding("GHSA-xxxx-xxxx-xxxx", "CVE-2023-0001"),
+			analysis: dtrack.Analysis{State: dtrack.AnalysisStateNotSet},
+			wantReq:  &dtrack.AnalysisRequest{Comment: comment, State: dtrack.AnalysisStateInTriage},
+			want:     []analysisResult{{Project: projectUUID, Component: "log4j-core:2.14.1", VulnID: "GHSA-xxxx-xxxx-xxxx", State: "IN_TRIAGE"}},
+		},
+		{
+			name:     "raise the state of a commented finding",
+			opts:     applyOptions{auditAnalysisState: "EXPLOITABLE"},
+			finding:  finding("CVE-2023-0001"),
+			analysis: dtrack.Analysis{State: dtrack.AnalysisStateInTriage, Comments: []dtrack.AnalysisComment{{Comment: comment}}},
+			wantReq:  &dtrack.AnalysisRequest{State: dtrack.AnalysisStateExploitable},
+			want:     recorded("EXPLOITABLE"),
+		},
+		{
+			name:     "keep the state decided by an auditor",
+			opts:     applyOptions{auditAnalysisState: "EXPLOITABLE"},
+			finding:  finding("CVE-2023-0001"),
+			analysis: dtrack.Analysis{State: dtrack.AnalysisStateNotAffected},
+			wantReq:  &dtrack.AnalysisRequest{Comment: comment, State: dtrack.AnalysisStateNotAffected},
+			want:     recorded("NOT_AFFECTED"),
+		},
+		{
+			name:     "already audited",
+			opts:     applyOptions{auditAnalysisState: "IN_TRIAGE"},
+			finding:  finding("CVE-2023-0001"),
+			analysis: dtrack.Analysis{State: dtrack.AnalysisStateExploitable, Comments: []dtrack.AnalysisComment{{Comment: comment}}},
+		},
+		{
+			name:       "dry run",
+			opts:       applyOptions{dryRun: true, auditAnalysisState: "EXPLOITABLE"},
+			finding:    finding("CVE-2023-0001"),
+			noAnalysis: true,
+			want:       recorded("EXPLOITABLE"),
+		},
+		{
+			name:    "not in the KEV catalog",
+			finding: finding("CVE-2023-9999"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mock.NewMockDependencyTrackClient(ctrl)
+			m.EXPECT().GetFindings(gomock.Any(), projectUUID).Return([]dtrack.Finding{tt.finding}, nil)
+			if tt.noAnalysis {
+				m.EXPECT().GetAnalysis(gomock.Any(), componentUUID, projectUUID, vulnerabilityUUID).Return(dtrack.Analysis{}, &dtrack.APIError{StatusCode: 404})
+			} else if tt.finding.Vulnerability.VulnID != "CVE-2023-9999" {
+				m.EXPECT().GetAnalysis(gomock.Any(), componentUUID, projectUUID, vulnerabilityUUID).Return(tt.analysis, nil)
+			}
+			if tt.wantReq != nil {
+				wantReq := *tt.wantReq
+				wantReq.Component = componentUUID
+				wantReq.Project = projectUUID
+				wantReq.Vulnerability = vulnerabilityUUID
+				m.EXPECT().RecordAnalysis(gomock.Any(), wantReq).Return(dtrack.Analysis{}, nil)
+			}
+
+			got, err := auditFindings(context.Background(), m, []uuid.UUID{projectUUID}, catalog, tt.opts)
+			if err != nil {
+				t.Fatalf("auditFindings() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("auditFindings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_auditState(t *testing.T) {
+	tests := []struct {
+		current dtrack.AnalysisState
+		target  dtrack.AnalysisState
+		want    dtrack.AnalysisState
+	}{
+		{"", "", ""},
+		{"", dtrack.AnalysisStateInTriage, dtrack.AnalysisStateInTriage},
+		{dtrack.AnalysisStateNotSet, dtrack.AnalysisStateExploitable, dtrack.AnalysisStateExploitable},
+		{dtrack.AnalysisStateInTriage, dtrack.AnalysisStateExploitable, dtrack.AnalysisStateExploitable},
+		{dtrack.AnalysisStateExploitable, dtrack.AnalysisStateInTriage, dtrack.AnalysisStateExploitable},
+		{dtrack.AnalysisStateFalsePositive, dtrack.AnalysisStateExploitable, dtrack.AnalysisStateFalsePositive},
+		{dtrack.AnalysisStateResolved, dtrack.AnalysisStateInTriage, dtrack.AnalysisStateResolved},
+	}
+	for _, tt := range tests {
+		if got := auditState(tt.current, tt.target); got != tt.want {
+			t.Errorf("auditState(%q, %q) = %q, want %q", tt.current, tt.target, got, tt.want)
+		}
+	}
+}
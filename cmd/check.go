@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	EXIT_CODE_ERROR = 1
+	EXIT_CODE_DRIFT = 2
+)
+
+// ErrDrift is returned by check when a policy differs from the desired state.
+// It is not printed; ExitCode turns it into EXIT_CODE_DRIFT.
+var ErrDrift = errors.New("policies differ from the desired state")
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check whether the policies differ from the desired state without changing them",
+	Long: `Compare the policies in Dependency Track with the desired state from the KEV catalog and the config,
+like --dry-run, and print the differences.
+Exits with 2 when a policy differs, and with 1 on errors.`,
+	SilenceUsage: true,
+	// drift is reported by the exit code, not as an error message
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newConfig()
+		if err != nil {
+			return err
+		}
+		c.DryRun = true
+		c.SkipUnchanged = false
+		// drift is about the policies; findings are not audited
+		c.Audit = false
+
+		output := viper.GetString("output")
+		if err := validateOutput(output); err != nil {
+			return err
+		}
+
+		results, err := sync(context.Background(), c, output, cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		if drifted(results) {
+			return ErrDrift
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+func drifted(results []*result) bool {
+	for _, r := range results {
+		if r.hasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitCode returns the exit code of the process failing with err.
+func ExitCode(err error) int {
+	if errors.Is(err, ErrDrift) {
+		return EXIT_CODE_DRIFT
+	}
+	return EXIT_CODE_ERROR
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_drifted(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []*result
+		want    bool
+	}{
+		{
+			name:    "no policies",
+			results: []*result{},
+			want:    false,
+		},
+		{
+			name:    "no changes",
+			results: []*result{{PolicyName: "kev"}, {PolicyName: "kev2"}},
+			want:    false,
+		},
+		{
+			name:    "policy condition added",
+			results: []*result{{PolicyName: "kev"}, {PolicyName: "kev2", PolicyConditionsAdded: []string{"CVE-2023-0001"}}},
+			want:    true,
+		},
+		{
+			name:    "policy updated",
+			results: []*result{{PolicyName: "kev", PolicyUpdated: true}},
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := drifted(tt.results); got != tt.want {
+				t.Errorf("drifted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "drift",
+			err:  ErrDrift,
+			want: EXIT_CODE_DRIFT,
+		},
+		{
+			name: "wrapped drift",
+			err:  fmt.Errorf("check: %w", ErrDrift),
+			want: EXIT_CODE_DRIFT,
+		},
+		{
+			name: "error",
+			err:  errors.New("error"),
+			want: EXIT_CODE_ERROR,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
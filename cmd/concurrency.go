@@ -0,0 +1,35 @@
+package cmd
+
+import "errors"
+
+// runConcurrently calls f for every item with at most concurrency calls at a time.
+// It returns the items f succeeded for, in the order of items, and the errors
+// of all the failed calls joined.
+func runConcurrently[T any](items []T, concurrency int, f func(T) error) (succeeded []T, err error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, concurrency)
+	for i, item := range items {
+		sem <- struct{}{}
+		go func(i int, item T) {
+			defer func() { <-sem }()
+
+			errs[i] = f(item)
+		}(i, item)
+	}
+	// wait for the running calls by taking every slot
+	for i := 0; i < concurrency; i++ {
+		sem <- struct{}{}
+	}
+
+	for i, item := range items {
+		if errs[i] == nil {
+			succeeded = append(succeeded, item)
+		}
+	}
+
+	return succeeded, errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_runConcurrently(t *testing.T) {
+	tests := []struct {
+		name          string
+		items         []int
+		concurrency   int
+		failing       map[int]bool
+		wantSucceeded []int
+		wantErrs      int
+	}{
+		{
+			name:          "all succeed",
+			items:         []int{1, 2, 3, 4, 5},
+			concurrency:   2,
+			wantSucceeded: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:          "errors are joined",
+			items:         []int{1, 2, 3, 4, 5},
+			concurrency:   3,
+			failing:       map[int]bool{2: true, 4: true},
+			wantSucceeded: []int{1, 3, 5},
+			wantErrs:      2,
+		},
+		{
+			name:          "zero concurrency runs sequentially",
+			items:         []int{1, 2},
+			concurrency:   0,
+			wantSucceeded: []int{1, 2},
+		},
+		{
+			name:        "no items",
+			items:       []int{},
+			concurrency: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, maxRunning int32
+			succeeded, err := runConcurrently(tt.items, tt.concurrency, func(i int) error {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+
+				if tt.failing[i] {
+					return fmt.Errorf("error %d", i)
+				}
+				return nil
+			})
+
+			if !reflect.DeepEqual(succeeded, tt.wantSucceeded) {
+				t.Errorf("runConcurrently() succeeded = %v, want %v", succeeded, tt.wantSucceeded)
+			}
+
+			gotErrs := 0
+			if err != nil {
+				var joined interface{ Unwrap() []error }
+				if errors.As(err, &joined) {
+					gotErrs = len(joined.Unwrap())
+				}
+			}
+			if gotErrs != tt.wantErrs {
+				t.Errorf("runConcurrently() errors = %v, want %d errors", err, tt.wantErrs)
+			}
+
+			concurrency := int32(tt.concurrency)
+			if concurrency < 1 {
+				concurrency = 1
+			}
+			if maxRunning > concurrency {
+				t.Errorf("runConcurrently() ran %d at a time, want at most %d", maxRunning, concurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"log"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/google/uuid"
+	"github.com/takumakume/kev-to-dependencytrack/state"
+)
+
+// keepOwned returns the entries of remove owned by kev-to-dependencytrack.
+// The others were added by someone else and are kept.
+func keepOwned[T any](kind string, remove []T, owns func(T) bool, name func(T) string) (owned []T) {
+	for _, o := range remove {
+		if !owns(o) {
+			log.Printf("apply %ss: keep %s %s not added by kev-to-dependencytrack", kind, kind, name(o))
+
+			continue
+		}
+		owned = append(owned, o)
+	}
+	return owned
+}
+
+// adopted returns the entries recorded as owned after an apply step: the added
+// ones, and on success also the desired ones which were already in the policy,
+// so that a policy populated before the state file existed is adopted.
+func adopted[T any](opts applyOptions, err error, added, desired []T) []T {
+	if err != nil || opts.dryRun {
+		return added
+	}
+	return desired
+}
+
+func tagNames(tags []dtrack.Tag) (names []string) {
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func statePolicyCondition(c dtrack.PolicyCondition) state.PolicyCondition {
+	return state.PolicyCondition{
+		Subject:  string(c.Subject),
+		Operator: string(c.Operator),
+		Value:    c.Value,
+	}
+}
+
+func statePolicyConditions(conditions []dtrack.PolicyCondition) (cc []state.PolicyCondition) {
+	for _, c := range conditions {
+		cc = append(cc, statePolicyCondition(c))
+	}
+	return cc
+}
+
+// forgottenPolicyConditions returns the removed policy conditions whose subject,
+// operator and value are not left in the policy. A removed duplicate is left
+// out, so that the kept condition stays owned.
+func forgottenPolicyConditions(current, removed []dtrack.PolicyCondition) (forgotten []dtrack.PolicyCondition) {
+	removedUUIDs := make(map[uuid.UUID]bool)
+	for _, c := range removed {
+		removedUUIDs[c.UUID] = true
+	}
+	kept := make(map[policyConditionKey]bool)
+	for _, c := range current {
+		if !removedUUIDs[c.UUID] {
+			kept[keyOf(c)] = true
+		}
+	}
+
+	for _, c := range removed {
+		if !kept[keyOf(c)] {
+			forgotten = append(forgotten, c)
+		}
+	}
+	return forgotten
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/takumakume/kev-to-dependencytrack/kev"
+	"github.com/takumakume/kev-to-dependencytrack/metrics"
+)
+
+func recordCatalogMetrics(catalog *kev.Catalog) {
+	metrics.KEVCatalogInfo.Reset()
+	metrics.KEVCatalogInfo.WithLabelValues(catalog.CatalogVersion, catalog.DateReleased).Set(1)
+	metrics.KEVCatalogCount.Set(float64(len(catalog.Vulnerabilities)))
+}
+
+// recordResultMetrics records the changes applied to the policies.
+func recordResultMetrics(results []*result) {
+	for _, r := range results {
+		if r.PolicyCreated {
+			metrics.ChangesTotal.WithLabelValues(r.PolicyName, "create_policy").Inc()
+		}
+		if r.PolicyUpdated {
+			metrics.ChangesTotal.WithLabelValues(r.PolicyName, "update_policy").Inc()
+		}
+		metrics.ChangesTotal.WithLabelValues(r.PolicyName, "add_tag").Add(float64(len(r.TagsAdded)))
+		metrics.ChangesTotal.WithLabelValues(r.PolicyName, "remove_tag").Add(float64(len(r.TagsRemoved)))
+		metrics.ChangesTotal.WithLabelValues(r.PolicyName, "add_project").Add(float64(len(r.ProjectsAdded)))
+		metrics.ChangesTotal.WithLabelValues(r.PolicyName, "remove_project").Add(float64(len(r.ProjectsRemoved)))
+		metrics.ChangesTotal.WithLabelValues(r.PolicyName, "add_policy_condition").Add(float64(len(r.PolicyConditionsAdded)))
+		metrics.ChangesTotal.WithLabelValues(r.PolicyName, "remove_policy_condition").Add(float64(len(r.PolicyConditionsRemoved)))
+		metrics.ChangesTotal.WithLabelValues(r.PolicyName, "record_analysis").Add(float64(len(r.AnalysesRecorded)))
+
+		if r.Error == "" && !r.Skipped {
+			metrics.PolicyConditions.WithLabelValues(r.PolicyName).Set(float64(r.PolicyConditionCount))
+		}
+	}
+}
+
+func recordSyncMetrics(err error) {
+	if err != nil {
+		metrics.SyncErrorsTotal.Inc()
+		return
+	}
+	metrics.LastSuccessTimestamp.SetToCurrentTime()
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/takumakume/kev-to-dependencytrack/kev"
+)
+
+const (
+	OUTPUT_TEXT = "text"
+	OUTPUT_JSON = "json"
+)
+
+// report is the outcome of a run over all policies.
+type report struct {
+	DryRun         bool      `json:"dryRun"`
+	CatalogVersion string    `json:"catalogVersion"`
+	DateReleased   string    `json:"dateReleased"`
+	Policies       []*result `json:"policies"`
+}
+
+func newReport(dryRun bool, catalog *kev.Catalog, results []*result) *report {
+	return &report{
+		DryRun:         dryRun,
+		CatalogVersion: catalog.CatalogVersion,
+		DateReleased:   catalog.DateReleased,
+		Policies:       results,
+	}
+}
+
+func validateOutput(output string) error {
+	switch output {
+	case OUTPUT_TEXT, OUTPUT_JSON:
+		return nil
+	}
+	return fmt.Errorf("invalid output %q: expected %s or %s", output, OUTPUT_TEXT, OUTPUT_JSON)
+}
+
+func (r *report) print(w io.Writer, output string) error {
+	switch output {
+	case OUTPUT_JSON:
+		for _, p := range r.Policies {
+			p.normalize()
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case OUTPUT_TEXT:
+		if r.DryRun {
+			fmt.Fprintln(w, "dry-run: no changes are applied")
+		}
+		for _, p := range r.Policies {
+			p.print(w)
+		}
+		return nil
+	}
+
+	return validateOutput(output)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/takumakume/kev-to-dependencytrack/kev"
+)
+
+func Test_report_print_json(t *testing.T) {
+	r := newReport(true, &kev.Catalog{CatalogVersion: "2023.07.01", DateReleased: "2023-07-01T00:00:00.000Z"}, []*result{
+		{
+			PolicyName:            "kev",
+			PolicyCreated:         true,
+			PolicyConditionsAdded: []string{"CVE-2023-0001"},
+		},
+	})
+
+	buf := &bytes.Buffer{}
+	if err := r.print(buf, OUTPUT_JSON); err != nil {
+		t.Fatalf("report.print() error = %v", err)
+	}
+
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal: %v: %s", err, buf.String())
+	}
+	if got["dryRun"] != true || got["catalogVersion"] != "2023.07.01" {
+		t.Errorf("unexpected report: %s", buf.String())
+	}
+
+	policies := got["policies"].([]interface{})
+	policy := policies[0].(map[string]interface{})
+	if policy["policyName"] != "kev" || policy["policyCreated"] != true {
+		t.Errorf("unexpected policy: %s", buf.String())
+	}
+	if _, ok := policy["tagsAdded"].([]interface{}); !ok {
+		t.Errorf("tagsAdded is not an array: %s", buf.String())
+	}
+	if _, ok := policy["error"]; ok {
+		t.Errorf("unexpected error: %s", buf.String())
+	}
+}
+
+func Test_report_print_invalidOutput(t *testing.T) {
+	r := newReport(false, &kev.Catalog{}, nil)
+	if err := r.print(&bytes.Buffer{}, "yaml"); err == nil {
+		t.Errorf("report.print() error = nil, want error")
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/google/uuid"
+)
+
+// result describes the changes run applied to a policy.
+// In dry-run mode nothing is applied and result is the plan of changes.
+type result struct {
+	PolicyName    string `json:"policyName"`
+	PolicyCreated bool   `json:"policyCreated"`
+	PolicyUpdated bool   `json:"policyUpdated"`
+
+	TagsAdded               []string    `json:"tagsAdded"`
+	TagsRemoved             []string    `json:"tagsRemoved"`
+	ProjectsAdded           []uuid.UUID `json:"projectsAdded"`
+	ProjectsRemoved         []uuid.UUID `json:"projectsRemoved"`
+	PolicyConditionsAdded   []string    `json:"policyConditionsAdded"`
+	PolicyConditionsRemoved []string    `json:"policyConditionsRemoved"`
+	PolicyConditionCount    int         `json:"policyConditionCount"`
+
+	AnalysesRecorded []analysisResult `json:"analysesRecorded,omitempty"`
+
+	// Skipped is set when nothing changed since the last successful run.
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// analysisResult describes an analysis recorded on a finding in audit mode.
+type analysisResult struct {
+	Project   uuid.UUID `json:"project"`
+	Component string    `json:"component"`
+	VulnID    string    `json:"vulnId"`
+	State     string    `json:"state,omitempty"`
+}
+
+func (r *result) setTags(removed, added []dtrack.Tag) {
+	for _, t := range removed {
+		r.TagsRemoved = append(r.TagsRemoved, t.Name)
+	}
+	for _, t := range added {
+		r.TagsAdded = append(r.TagsAdded, t.Name)
+	}
+}
+
+func (r *result) setProjects(removed, added []uuid.UUID) {
+	r.ProjectsRemoved = append(r.ProjectsRemoved, removed...)
+	r.ProjectsAdded = append(r.ProjectsAdded, added...)
+}
+
+func (r *result) setPolicyConditions(removed, added []dtrack.PolicyCondition) {
+	for _, c := range removed {
+		r.PolicyConditionsRemoved = append(r.PolicyConditionsRemoved, c.Value)
+	}
+	for _, c := range added {
+		r.PolicyConditionsAdded = append(r.PolicyConditionsAdded, c.Value)
+	}
+}
+
+func (r *result) hasChanges() bool {
+	return r.PolicyCreated || r.PolicyUpdated ||
+		len(r.TagsAdded) > 0 || len(r.TagsRemoved) > 0 ||
+		len(r.ProjectsAdded) > 0 || len(r.ProjectsRemoved) > 0 ||
+		len(r.PolicyConditionsAdded) > 0 || len(r.PolicyConditionsRemoved) > 0 ||
+		len(r.AnalysesRecorded) > 0
+}
+
+// normalize replaces nil slices with empty ones, so that they are encoded as [] instead of null.
+func (r *result) normalize() {
+	for _, ss := range []*[]string{&r.TagsAdded, &r.TagsRemoved, &r.PolicyConditionsAdded, &r.PolicyConditionsRemoved} {
+		if *ss == nil {
+			*ss = []string{}
+		}
+	}
+	for _, uu := range []*[]uuid.UUID{&r.ProjectsAdded, &r.ProjectsRemoved} {
+		if *uu == nil {
+			*uu = []uuid.UUID{}
+		}
+	}
+}
+
+// print writes r in a human readable form, "+" for additions and "-" for removals.
+func (r *result) print(w io.Writer) {
+	switch {
+	case r.PolicyCreated:
+		fmt.Fprintf(w, "policy %q: create\n", r.PolicyName)
+	case r.PolicyUpdated:
+		fmt.Fprintf(w, "policy %q: update\n", r.PolicyName)
+	default:
+		fmt.Fprintf(w, "policy %q\n", r.PolicyName)
+	}
+
+	if r.Error != "" {
+		fmt.Fprintf(w, "  error: %s\n", r.Error)
+	}
+
+	if r.Skipped {
+		fmt.Fprintln(w, "  skipped: unchanged since the last run")
+	} else if !r.hasChanges() {
+		fmt.Fprintln(w, "  no changes")
+		return
+	}
+
+	for _, t := range r.TagsRemoved {
+		fmt.Fprintf(w, "  - tag: %s\n", t)
+	}
+	for _, t := range r.TagsAdded {
+		fmt.Fprintf(w, "  + tag: %s\n", t)
+	}
+	for _, p := range r.ProjectsRemoved {
+		fmt.Fprintf(w, "  - project: %s\n", p)
+	}
+	for _, p := range r.ProjectsAdded {
+		fmt.Fprintf(w, "  + project: %s\n", p)
+	}
+	for _, c := range r.PolicyConditionsRemoved {
+		fmt.Fprintf(w, "  - policyCondition: %s\n", c)
+	}
+	for _, c := range r.PolicyConditionsAdded {
+		fmt.Fprintf(w, "  + policyCondition: %s\n", c)
+	}
+	for _, a := range r.AnalysesRecorded {
+		fmt.Fprintf(w, "  * analysis: %s in %s of project %s", a.VulnID, a.Component, a.Project)
+		if a.State != "" {
+			fmt.Fprintf(w, ": %s", a.State)
+		}
+		fmt.Fprintln(w)
+	}
+}
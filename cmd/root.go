@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -13,39 +16,32 @@ import (
 	"github.com/spf13/viper"
 	"github.com/takumakume/kev-to-dependencytrack/config"
 	"github.com/takumakume/kev-to-dependencytrack/dependencytrack"
+	"github.com/takumakume/kev-to-dependencytrack/httpclient"
 	"github.com/takumakume/kev-to-dependencytrack/kev"
+	"github.com/takumakume/kev-to-dependencytrack/metrics"
+	"github.com/takumakume/kev-to-dependencytrack/state"
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "kev-to-dependencytrack",
 	Short: "",
 	Long:  ``,
+	// the error is printed to stderr by main, so that stdout holds only the report
+	SilenceErrors: true,
+	SilenceUsage:  true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
-		c := config.New(
-			viper.GetString("base-url"),
-			viper.GetString("api-key"),
-			viper.GetString("policy-name"),
-			viper.GetString("policy-operator"),
-			viper.GetString("policy-violation-state"),
-			viper.GetStringSlice("policy-projects"),
-			viper.GetStringSlice("policy-tags"),
-		)
-		if err := c.Validate(); err != nil {
-			return err
-		}
-
-		k := kev.New()
-		if err := k.Init(); err != nil {
+		c, err := newConfig()
+		if err != nil {
 			return err
 		}
 
-		dtrackClient, err := dependencytrack.New(c.BaseURL, c.APIKey, 10*time.Second)
-		if err != nil {
+		output := viper.GetString("output")
+		if err := validateOutput(output); err != nil {
 			return err
 		}
 
-		return run(ctx, dtrackClient, c, k.Catalog().VulnerabilitiyIDs())
+		return syncOnce(ctx, c, output, cmd.OutOrStdout())
 	},
 }
 
@@ -55,21 +51,103 @@ func init() {
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.SetEnvPrefix("DT")
 
+	flags.StringP("config", "c", "", "Config file describing the policies (env: DT_CONFIG)")
 	flags.StringP("base-url", "u", "http://127.0.0.1:8081/", "Dependency Track base URL (env: DT_BASE_URL)")
 	flags.StringP("api-key", "k", "", "Dependency Track API key (env: DT_API_KEY)")
+	flags.DurationP("timeout", "", 10*time.Second, "Timeout of a Dependency Track API request")
+	flags.StringP("ca-file", "", "", "PEM bundle of CA certificates trusted for Dependency Track in addition to the system ones")
+	flags.StringP("client-cert-file", "", "", "PEM client certificate for mutual TLS with Dependency Track")
+	flags.StringP("client-key-file", "", "", "PEM client key for mutual TLS with Dependency Track")
+	flags.BoolP("insecure-skip-verify", "", false, "Do not verify the Dependency Track server certificate (for testing only)")
+	flags.StringP("proxy", "", "", "HTTP proxy URL for Dependency Track (default: HTTP_PROXY, HTTPS_PROXY and NO_PROXY)")
 	flags.StringP("policy-name", "", "", "Dependency Track policy name")
-	flags.StringP("policy-operator", "", "ANY", "Dependency Track policy operator")
-	flags.StringP("policy-violation-state", "", "WARN", "Dependency Track policy violationState")
-	flags.StringSliceP("policy-projects", "", []string{}, "Dependency Track policy projects")
+	flags.StringP("policy-operator", "", config.DefaultPolicyOperator, "Dependency Track policy operator")
+	flags.StringP("policy-violation-state", "", config.DefaultPolicyViolationState, "Dependency Track policy violationState")
+	flags.StringSliceP("policy-projects", "", []string{}, "Dependency Track policy projects: <name>[:<version>], tag:<tag>, regex:<regexp> or classifier:<classifier>, prefixed with ! to exclude")
 	flags.StringSliceP("policy-tags", "", []string{}, "Dependency Track policy tags")
+	flags.BoolP("policy-include-children", "", false, "Add the child projects of the policy projects recursively")
+	flags.BoolP("policy-include-inactive", "", false, "Select inactive projects as policy projects")
+	flags.StringSliceP("filter-vendor-projects", "", []string{}, "Only KEV entries of these vendorProjects become policy conditions")
+	flags.StringSliceP("filter-products", "", []string{}, "Only KEV entries of these products become policy conditions")
+	flags.StringP("filter-date-added-since", "", "", "Only KEV entries added on or after this date (YYYY-MM-DD or <days>d) become policy conditions")
+	flags.StringP("filter-date-added-until", "", "", "Only KEV entries added on or before this date (YYYY-MM-DD or <days>d) become policy conditions")
+	flags.StringP("filter-due-date-since", "", "", "Only KEV entries due on or after this date (YYYY-MM-DD or <days>d) become policy conditions")
+	flags.StringP("filter-due-date-until", "", "", "Only KEV entries due on or before this date (YYYY-MM-DD or <days>d) become policy conditions")
+	flags.StringP("filter-cve-id-regex", "", "", "Only KEV entries whose cveID matches this regular expression become policy conditions")
+	flags.StringP("filter-known-ransomware-campaign-use", "", "", "Only KEV entries with this knownRansomwareCampaignUse (Known or Unknown) become policy conditions")
+	flags.StringSliceP("filter-cwes", "", []string{}, "Only KEV entries having one of these CWEs become policy conditions")
+	flags.StringP("kev-url", "", kev.DEFAULT_KEV_CATALOG_JSON_URL, "KEV catalog JSON URL")
+	flags.StringP("cache-dir", "", "", "Directory to cache the KEV catalog in (default: $TMPDIR/kev-to-dependencytrack)")
+	flags.DurationP("kev-max-age", "", kev.DEFAULT_MAX_AGE, "How long the cached KEV catalog is used before it is checked for updates")
+	flags.DurationP("kev-timeout", "", kev.DEFAULT_TIMEOUT, "Timeout of the KEV catalog download")
+	flags.StringP("kev-ca-file", "", "", "PEM bundle of CA certificates trusted for the KEV catalog download in addition to the system ones")
+	flags.StringP("kev-proxy", "", "", "HTTP proxy URL for the KEV catalog download (default: HTTP_PROXY, HTTPS_PROXY and NO_PROXY)")
+	flags.StringP("kev-file", "", "", "Read the KEV catalog JSON from this file (\"-\" for stdin) instead of downloading it")
+	flags.IntP("retry-max-attempts", "", 5, "Number of attempts of a Dependency Track API call failing with a transient error; 1 disables retries")
+	flags.DurationP("retry-initial-interval", "", time.Second, "Wait before the first retry of a Dependency Track API call, doubled on each retry")
+	flags.DurationP("retry-max-interval", "", 30*time.Second, "Maximum wait between retries of a Dependency Track API call")
+	flags.DurationP("retry-max-elapsed-time", "", 2*time.Minute, "Maximum time spent on a Dependency Track API call including retries; 0 for no limit")
+	flags.BoolP("dry-run", "", false, "Print the changes without applying them to Dependency Track")
+	flags.StringP("output", "o", OUTPUT_TEXT, "Output format of the changes: text or json")
+	flags.StringP("metrics-textfile", "", "", "Write Prometheus metrics to this file for the node_exporter textfile collector")
+	flags.StringP("max-policy-condition-removals", "", config.DefaultMaxPolicyConditionRemovals, "Refuse to remove more than this number (e.g. 100) or percentage (e.g. 50%) of the policy conditions of a policy at once (default no limit)")
+	flags.BoolP("force", "", false, "Remove policy conditions even when max-policy-condition-removals is exceeded")
+	flags.BoolP("strict", "", false, "Abort the run before changing any policy when a policy project selector matches no project")
+	flags.StringP("state-file", "", "", "File recording the tags, projects and policy conditions added by kev-to-dependencytrack")
+	flags.BoolP("managed", "", false, "Only remove the tags, projects and policy conditions recorded in the state-file")
+	flags.BoolP("skip-unchanged", "", false, "Skip the run when the state-file records a successful run with the same KEV catalog, config and CVEs of the policies")
+	flags.IntP("concurrency", "", config.DefaultConcurrency, "Number of policy conditions created or deleted at a time")
+	flags.BoolP("audit-findings", "", false, "Record the KEV entry as an analysis comment on the findings of the policy projects in the KEV catalog")
+	flags.StringP("audit-analysis-state", "", "", "Raise the analysis state of the audited findings to EXPLOITABLE or IN_TRIAGE")
 
+	viper.BindPFlag("config", flags.Lookup("config"))
 	viper.BindPFlag("base-url", flags.Lookup("base-url"))
 	viper.BindPFlag("api-key", flags.Lookup("api-key"))
+	viper.BindPFlag("timeout", flags.Lookup("timeout"))
+	viper.BindPFlag("ca-file", flags.Lookup("ca-file"))
+	viper.BindPFlag("client-cert-file", flags.Lookup("client-cert-file"))
+	viper.BindPFlag("client-key-file", flags.Lookup("client-key-file"))
+	viper.BindPFlag("insecure-skip-verify", flags.Lookup("insecure-skip-verify"))
+	viper.BindPFlag("proxy", flags.Lookup("proxy"))
 	viper.BindPFlag("policy-name", flags.Lookup("policy-name"))
 	viper.BindPFlag("policy-operator", flags.Lookup("policy-operator"))
 	viper.BindPFlag("policy-violation-state", flags.Lookup("policy-violation-state"))
 	viper.BindPFlag("policy-projects", flags.Lookup("policy-projects"))
 	viper.BindPFlag("policy-tags", flags.Lookup("policy-tags"))
+	viper.BindPFlag("policy-include-children", flags.Lookup("policy-include-children"))
+	viper.BindPFlag("policy-include-inactive", flags.Lookup("policy-include-inactive"))
+	viper.BindPFlag("filter-vendor-projects", flags.Lookup("filter-vendor-projects"))
+	viper.BindPFlag("filter-products", flags.Lookup("filter-products"))
+	viper.BindPFlag("filter-date-added-since", flags.Lookup("filter-date-added-since"))
+	viper.BindPFlag("filter-date-added-until", flags.Lookup("filter-date-added-until"))
+	viper.BindPFlag("filter-due-date-since", flags.Lookup("filter-due-date-since"))
+	viper.BindPFlag("filter-due-date-until", flags.Lookup("filter-due-date-until"))
+	viper.BindPFlag("filter-cve-id-regex", flags.Lookup("filter-cve-id-regex"))
+	viper.BindPFlag("filter-known-ransomware-campaign-use", flags.Lookup("filter-known-ransomware-campaign-use"))
+	viper.BindPFlag("filter-cwes", flags.Lookup("filter-cwes"))
+	viper.BindPFlag("kev-url", flags.Lookup("kev-url"))
+	viper.BindPFlag("cache-dir", flags.Lookup("cache-dir"))
+	viper.BindPFlag("kev-max-age", flags.Lookup("kev-max-age"))
+	viper.BindPFlag("kev-timeout", flags.Lookup("kev-timeout"))
+	viper.BindPFlag("kev-ca-file", flags.Lookup("kev-ca-file"))
+	viper.BindPFlag("kev-proxy", flags.Lookup("kev-proxy"))
+	viper.BindPFlag("kev-file", flags.Lookup("kev-file"))
+	viper.BindPFlag("retry-max-attempts", flags.Lookup("retry-max-attempts"))
+	viper.BindPFlag("retry-initial-interval", flags.Lookup("retry-initial-interval"))
+	viper.BindPFlag("retry-max-interval", flags.Lookup("retry-max-interval"))
+	viper.BindPFlag("retry-max-elapsed-time", flags.Lookup("retry-max-elapsed-time"))
+	viper.BindPFlag("dry-run", flags.Lookup("dry-run"))
+	viper.BindPFlag("output", flags.Lookup("output"))
+	viper.BindPFlag("metrics-textfile", flags.Lookup("metrics-textfile"))
+	viper.BindPFlag("max-policy-condition-removals", flags.Lookup("max-policy-condition-removals"))
+	viper.BindPFlag("force", flags.Lookup("force"))
+	viper.BindPFlag("strict", flags.Lookup("strict"))
+	viper.BindPFlag("state-file", flags.Lookup("state-file"))
+	viper.BindPFlag("managed", flags.Lookup("managed"))
+	viper.BindPFlag("skip-unchanged", flags.Lookup("skip-unchanged"))
+	viper.BindPFlag("concurrency", flags.Lookup("concurrency"))
+	viper.BindPFlag("audit-findings", flags.Lookup("audit-findings"))
+	viper.BindPFlag("audit-analysis-state", flags.Lookup("audit-analysis-state"))
 }
 
 func Execute() error {
@@ -79,145 +157,580 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
-func run(ctx context.Context, client dependencytrack.DependencyTrackClient, config *config.Config, cves []string) error {
-	desierdPolicy := desierdPolicy(config.PolicyName, config.PolicyOperator, config.PolicyViolationState)
-	policy, err := applyPolicy(ctx, client, desierdPolicy)
+// newKEV returns the KEV catalog source configured by the kev-* flags.
+func newKEV() (*kev.KEV, error) {
+	if f := viper.GetString("kev-file"); f != "" {
+		return kev.NewFromFile(f), nil
+	}
+
+	httpClient, err := httpclient.New(httpclient.Options{
+		Timeout: viper.GetDuration("kev-timeout"),
+		CAFile:  viper.GetString("kev-ca-file"),
+		Proxy:   viper.GetString("kev-proxy"),
+	})
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("kev: %w", err)
+	}
+
+	return kev.New(
+		kev.WithURL(viper.GetString("kev-url")),
+		kev.WithCacheDir(viper.GetString("cache-dir")),
+		kev.WithMaxAge(viper.GetDuration("kev-max-age")),
+		kev.WithHTTPClient(httpClient),
+	), nil
+}
+
+// syncOnce loads the KEV catalog, reconciles every policy and prints the report to w.
+// The metrics are written to the metrics-textfile when given.
+func syncOnce(ctx context.Context, c *config.Config, output string, w io.Writer) error {
+	_, err := sync(ctx, c, output, w)
+	recordSyncMetrics(err)
+
+	if f := viper.GetString("metrics-textfile"); f != "" {
+		if werr := metrics.WriteToTextfile(f); werr != nil {
+			log.Printf("WARN: write metrics textfile: %v", werr)
+		}
+	}
+
+	return err
+}
+
+func sync(ctx context.Context, c *config.Config, output string, w io.Writer) ([]*result, error) {
+	k, err := newKEV()
+	if err != nil {
+		return nil, err
+	}
+	if err := k.Init(); err != nil {
+		return nil, err
+	}
+	recordCatalogMetrics(k.Catalog())
+
+	httpClient, err := httpclient.New(httpclient.Options{
+		Timeout:            viper.GetDuration("timeout"),
+		CAFile:             viper.GetString("ca-file"),
+		CertFile:           viper.GetString("client-cert-file"),
+		KeyFile:            viper.GetString("client-key-file"),
+		InsecureSkipVerify: viper.GetBool("insecure-skip-verify"),
+		Proxy:              viper.GetString("proxy"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dtrackClient, err := dependencytrack.New(c.BaseURL, c.APIKey, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	client := metrics.InstrumentClient(dependencytrack.WithRetry(dtrackClient, c.Retry))
+
+	results, err := run(ctx, client, c, k.Catalog())
+	if !c.DryRun {
+		recordResultMetrics(results)
+	}
+	if perr := newReport(c.DryRun, k.Catalog(), results).print(w, output); perr != nil {
+		return results, errors.Join(err, perr)
 	}
 
-	tags := desierdTags(config.PolicyTags)
-	if err := applyTags(ctx, client, policy, tags); err != nil {
-		return err
+	return results, err
+}
+
+// newConfig builds the config from the flags, the environment variables and
+// the config file. Policies are read from the "policies" key of the config
+// file, otherwise a single policy is built from the policy-* flags.
+func newConfig() (*config.Config, error) {
+	if f := viper.GetString("config"); f != "" {
+		viper.SetConfigFile(f)
+		if err := viper.ReadInConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	policies := []config.Policy{}
+	if viper.IsSet("policies") {
+		if viper.GetString("policy-name") != "" {
+			return nil, errors.New("policy-name cannot be used with policies in the config file")
+		}
+		if err := viper.UnmarshalKey("policies", &policies, viper.DecodeHook(config.DecodeHook())); err != nil {
+			return nil, err
+		}
+	} else {
+		policy := config.NewPolicy(
+			viper.GetString("policy-name"),
+			viper.GetString("policy-operator"),
+			viper.GetString("policy-violation-state"),
+			viper.GetStringSlice("policy-projects"),
+			viper.GetStringSlice("policy-tags"),
+			config.Filter{
+				VendorProjects: viper.GetStringSlice("filter-vendor-projects"),
+				Products:       viper.GetStringSlice("filter-products"),
+				DateAddedSince: viper.GetString("filter-date-added-since"),
+				DateAddedUntil: viper.GetString("filter-date-added-until"),
+				DueDateSince:   viper.GetString("filter-due-date-since"),
+				DueDateUntil:   viper.GetString("filter-due-date-until"),
+				CveIDRegex:     viper.GetString("filter-cve-id-regex"),
+
+				KnownRansomwareCampaignUse: viper.GetString("filter-known-ransomware-campaign-use"),
+				CWEs:                       viper.GetStringSlice("filter-cwes"),
+			},
+		)
+		policy.IncludeChildren = viper.GetBool("policy-include-children")
+		policy.IncludeInactive = viper.GetBool("policy-include-inactive")
+		policies = append(policies, policy)
 	}
 
-	projectUUIDs, err := desierdProjectUUIDs(ctx, client, config.PolicyProjects)
+	c := config.New(
+		viper.GetString("base-url"),
+		viper.GetString("api-key"),
+		policies,
+		viper.GetBool("dry-run"),
+	)
+	c.MaxPolicyConditionRemovals = viper.GetString("max-policy-condition-removals")
+	c.Force = viper.GetBool("force")
+	c.Strict = viper.GetBool("strict")
+	if concurrency := viper.GetInt("concurrency"); concurrency != 0 {
+		c.Concurrency = concurrency
+	}
+	c.StateFile = viper.GetString("state-file")
+	c.Managed = viper.GetBool("managed")
+	c.SkipUnchanged = viper.GetBool("skip-unchanged")
+	c.Audit = viper.GetBool("audit-findings")
+	c.AuditAnalysisState = viper.GetString("audit-analysis-state")
+	c.Retry = dependencytrack.RetryOptions{
+		MaxAttempts:     viper.GetInt("retry-max-attempts"),
+		InitialInterval: viper.GetDuration("retry-initial-interval"),
+		MaxInterval:     viper.GetDuration("retry-max-interval"),
+		MaxElapsedTime:  viper.GetDuration("retry-max-elapsed-time"),
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// applyOptions controls how the apply* functions change Dependency Track.
+type applyOptions struct {
+	dryRun                     bool
+	maxPolicyConditionRemovals config.Threshold
+	force                      bool
+	concurrency                int
+
+	// audit and auditAnalysisState are the Audit settings of the config.
+	audit              bool
+	auditAnalysisState string
+
+	// owned is set in managed mode; only the entries it owns are removed.
+	owned *state.Policy
+}
+
+// run reconciles every policy of the config. A failing policy does not stop
+// the others; the errors are joined.
+func run(ctx context.Context, client dependencytrack.DependencyTrackClient, c *config.Config, catalog *kev.Catalog) ([]*result, error) {
+	maxPolicyConditionRemovals, err := config.ParseThreshold(c.MaxPolicyConditionRemovals)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	opts := applyOptions{
+		dryRun:                     c.DryRun,
+		maxPolicyConditionRemovals: maxPolicyConditionRemovals,
+		force:                      c.Force,
+		concurrency:                c.Concurrency,
+		audit:                      c.Audit,
+		auditAnalysisState:         c.AuditAnalysisState,
+	}
+
+	var st *state.State
+	var configHash string
+	if c.StateFile != "" {
+		if st, err = state.Load(c.StateFile); err != nil {
+			return nil, fmt.Errorf("load state: %w", err)
+		}
+		if configHash, err = c.Hash(); err != nil {
+			return nil, err
+		}
 	}
-	if err := applyProjects(ctx, client, policy, projectUUIDs); err != nil {
-		return err
+
+	if c.SkipUnchanged && st != nil {
+		cves, err := desierdCVEs(c.Policies, catalog)
+		if err != nil {
+			return nil, err
+		}
+		if st.Unchanged(catalog.CatalogVersion, catalog.DateReleased, configHash, cves) {
+			log.Printf("skip: nothing changed since the run at %s", st.LastApplied.AppliedAt.Format(time.RFC3339))
+
+			// findings appear in Dependency Track independently of the KEV
+			// catalog, so they are audited also when the policies are skipped
+			results := []*result{}
+			var errs []error
+			for _, p := range c.Policies {
+				r := &result{PolicyName: p.Name, Skipped: true}
+				results = append(results, r)
+				if !c.Audit {
+					continue
+				}
+				var err error
+				if r.AnalysesRecorded, err = auditPolicy(ctx, client, p, catalog, opts); err != nil {
+					r.Error = err.Error()
+					errs = append(errs, fmt.Errorf("policy %q: %w", p.Name, err))
+				}
+			}
+			return results, errors.Join(errs...)
+		}
+	}
+
+	// the project selectors of every policy are resolved before any policy is
+	// changed, so that strict mode aborts the run without changes
+	projects := make([]resolvedProjects, len(c.Policies))
+	results := []*result{}
+	var errs []error
+	for i, p := range c.Policies {
+		projects[i].uuids, projects[i].unresolved, projects[i].err = desierdProjectUUIDs(ctx, client, p.Projects, p.IncludeChildren, p.IncludeInactive)
+		if len(projects[i].unresolved) > 0 && c.Strict {
+			err := fmt.Errorf("project selectors match no project: %s", strings.Join(projects[i].unresolved, ", "))
+			results = append(results, &result{PolicyName: p.Name, Error: err.Error()})
+			errs = append(errs, fmt.Errorf("policy %q: %w", p.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return results, errors.Join(errs...)
+	}
+
+	for i, p := range c.Policies {
+		var owned *state.Policy
+		if st != nil {
+			owned = st.Policy(p.Name)
+		}
+		policyOpts := opts
+		if c.Managed {
+			policyOpts.owned = owned
+		}
+
+		r, err := runPolicy(ctx, client, p, projects[i], policyOpts, catalog, owned)
+		results = append(results, r)
+		if err != nil {
+			r.Error = err.Error()
+			errs = append(errs, fmt.Errorf("policy %q: %w", p.Name, err))
+		}
+	}
+
+	// the changes are recorded also when a policy failed, as they were applied
+	if st != nil && !c.DryRun {
+		st.LastApplied = nil
+		if len(errs) == 0 {
+			st.LastApplied = &state.Applied{
+				CatalogVersion: catalog.CatalogVersion,
+				DateReleased:   catalog.DateReleased,
+				ConfigHash:     configHash,
+				AppliedAt:      time.Now().UTC(),
+			}
+		}
+		if err := st.Save(c.StateFile); err != nil {
+			errs = append(errs, fmt.Errorf("save state: %w", err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// resolvedProjects is the result of desierdProjectUUIDs for a policy.
+type resolvedProjects struct {
+	uuids      []uuid.UUID
+	unresolved []string
+	err        error
+}
+
+// runPolicy reconciles a policy to the resolved projects. The applied changes
+// are recorded in owned unless it is nil.
+func runPolicy(ctx context.Context, client dependencytrack.DependencyTrackClient, config config.Policy, projects resolvedProjects, opts applyOptions, catalog *kev.Catalog, owned *state.Policy) (*result, error) {
+	if owned == nil {
+		owned = &state.Policy{}
+	}
+
+	r := &result{
+		PolicyName: config.Name,
+	}
+
+	filter, err := config.Filter.KEVFilter(time.Now())
+	if err != nil {
+		return r, err
+	}
+	filtered := catalog.Filter(filter)
+	log.Printf("policy %s: %d of %d KEV entries match the filter", config.Name, filtered.Count, len(catalog.Vulnerabilities))
+
+	if projects.err != nil {
+		return r, projects.err
+	}
+	projectUUIDs, unresolved := projects.uuids, projects.unresolved
+
+	desierdPolicy := desierdPolicy(config.Name, config.Operator, config.ViolationState)
+	policy, created, updated, err := applyPolicy(ctx, client, desierdPolicy, opts)
+	r.PolicyCreated = created
+	r.PolicyUpdated = updated
+	if err != nil {
+		return r, err
+	}
+
+	tags := desierdTags(config.Tags)
+	removedTags, addedTags, err := applyTags(ctx, client, policy, tags, opts)
+	r.setTags(removedTags, addedTags)
+	owned.UpdateTags(tagNames(removedTags), tagNames(adopted(opts, err, addedTags, tags)))
+	if err != nil {
+		return r, err
+	}
+
+	// the projects kept for an unresolved selector are not adopted
+	resolvedProjectUUIDs := projectUUIDs
+	projectUUIDs, err = protectProjects(policy.Projects, projectUUIDs, unresolved)
+	if err != nil {
+		return r, err
+	}
+	removedProjects, addedProjects, err := applyProjects(ctx, client, policy, projectUUIDs, opts)
+	r.setProjects(removedProjects, addedProjects)
+	owned.UpdateProjects(removedProjects, adopted(opts, err, addedProjects, resolvedProjectUUIDs))
+	if err != nil {
+		return r, err
+	}
+
+	desierdPolicyConditions := desierdPolicyConditions(filtered.VulnerabilitiyIDs())
+	removedConditions, addedConditions, err := applyPolicyConditions(ctx, client, policy, desierdPolicyConditions, opts)
+	r.setPolicyConditions(removedConditions, addedConditions)
+	owned.UpdatePolicyConditions(statePolicyConditions(forgottenPolicyConditions(policy.PolicyConditions, removedConditions)), statePolicyConditions(adopted(opts, err, addedConditions, desierdPolicyConditions)))
+	r.PolicyConditionCount = len(policy.PolicyConditions) - len(removedConditions) + len(addedConditions)
+	if err == nil {
+		owned.Applied(policy.UUID, filtered.VulnerabilitiyIDs())
+	}
+
+	if opts.audit {
+		auditProjectUUIDs, auditErr := auditedProjectUUIDs(ctx, client, projectUUIDs)
+		if auditErr == nil {
+			r.AnalysesRecorded, auditErr = auditFindings(ctx, client, auditProjectUUIDs, filtered, opts)
+		}
+		err = errors.Join(err, auditErr)
+	}
+
+	return r, err
+}
+
+// auditPolicy audits the findings of the projects of a policy without
+// reconciling the policy.
+func auditPolicy(ctx context.Context, client dependencytrack.DependencyTrackClient, config config.Policy, catalog *kev.Catalog, opts applyOptions) ([]analysisResult, error) {
+	filter, err := config.Filter.KEVFilter(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	projectUUIDs, _, err := desierdProjectUUIDs(ctx, client, config.Projects, config.IncludeChildren, config.IncludeInactive)
+	if err != nil {
+		return nil, err
+	}
+	if projectUUIDs, err = auditedProjectUUIDs(ctx, client, projectUUIDs); err != nil {
+		return nil, err
+	}
+
+	return auditFindings(ctx, client, projectUUIDs, catalog.Filter(filter), opts)
+}
+
+// auditedProjectUUIDs returns the projects whose findings are audited: the
+// projects of the policy, or every active project when the policy applies to
+// all projects.
+func auditedProjectUUIDs(ctx context.Context, client dependencytrack.DependencyTrackClient, projectUUIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if len(projectUUIDs) > 0 {
+		return projectUUIDs, nil
 	}
 
-	desierdPolicyConditions := desierdPolicyConditions(cves)
-	if err := applyPolicyConditions(ctx, client, policy, desierdPolicyConditions); err != nil {
-		return err
+	projects, err := client.GetProjects(ctx)
+	if err != nil {
+		return nil, err
 	}
+	uuids := []uuid.UUID{}
+	for _, p := range projects {
+		if p.Active {
+			uuids = append(uuids, p.UUID)
+		}
+	}
+	return uuids, nil
+}
 
-	return nil
+// desierdCVEs returns the CVE IDs of the KEV catalog matching the filter of each policy.
+func desierdCVEs(policies []config.Policy, catalog *kev.Catalog) (map[string][]string, error) {
+	cves := make(map[string][]string)
+	for _, p := range policies {
+		filter, err := p.Filter.KEVFilter(time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("policy %q: %w", p.Name, err)
+		}
+		cves[p.Name] = catalog.Filter(filter).VulnerabilitiyIDs()
+	}
+	return cves, nil
 }
 
-func applyPolicy(ctx context.Context, client dependencytrack.DependencyTrackClient, desierdPolicy dtrack.Policy) (policy dtrack.Policy, err error) {
+// applyPolicy creates or updates the policy. In dry-run mode it returns
+// the policy as it would be after the changes without calling any mutating
+// method of the client.
+func applyPolicy(ctx context.Context, client dependencytrack.DependencyTrackClient, desierdPolicy dtrack.Policy, opts applyOptions) (policy dtrack.Policy, created, updated bool, err error) {
 	if policy, err = client.GetPolicyForName(ctx, desierdPolicy.Name); err != nil {
 		if dependencytrack.IsNotFound(err) {
 			log.Printf("apply policy: create policy: %s", desierdPolicy.Name)
 
-			policy, err = client.CreatePolicy(ctx, desierdPolicy)
-			if err != nil {
-				return policy, err
+			if opts.dryRun {
+				return desierdPolicy, true, false, nil
 			}
-			// FIXME: https://github.com/DependencyTrack/dependency-track/issues/2365
-			policy.Operator = dtrack.PolicyOperator(desierdPolicy.Operator)
-			policy.ViolationState = dtrack.PolicyViolationState(desierdPolicy.ViolationState)
-			policy, err = client.UpdatePolicy(ctx, policy)
+
+			policy, err = client.CreatePolicy(ctx, desierdPolicy)
 			if err != nil {
-				return policy, err
+				return policy, false, false, err
 			}
-		} else {
-			return policy, err
+			return policy, true, false, nil
 		}
+		return policy, false, false, err
+	}
 
-	} else {
-		if client.NeedsUpdatePolicy(policy, desierdPolicy) {
-			log.Printf("apply policy: update policy: %s", desierdPolicy.Name)
+	if client.NeedsUpdatePolicy(policy, desierdPolicy) {
+		log.Printf("apply policy: update policy: %s", desierdPolicy.Name)
 
-			policy.Operator = desierdPolicy.Operator
-			policy.ViolationState = desierdPolicy.ViolationState
-			policy, err = client.UpdatePolicy(ctx, policy)
-			if err != nil {
-				return policy, err
-			}
+		policy.Operator = desierdPolicy.Operator
+		policy.ViolationState = desierdPolicy.ViolationState
+		if opts.dryRun {
+			return policy, false, true, nil
+		}
+
+		policy, err = client.UpdatePolicy(ctx, policy)
+		if err != nil {
+			return policy, false, false, err
 		}
+		return policy, false, true, nil
 	}
 
-	return policy, err
+	return policy, false, false, nil
 }
 
-func applyTags(ctx context.Context, client dependencytrack.DependencyTrackClient, policy dtrack.Policy, tags []dtrack.Tag) error {
+func applyTags(ctx context.Context, client dependencytrack.DependencyTrackClient, policy dtrack.Policy, tags []dtrack.Tag, opts applyOptions) (removed, added []dtrack.Tag, err error) {
 	remove, add := compareTags(policy.Tags, tags)
+	if opts.owned != nil {
+		remove = keepOwned("tag", remove,
+			func(o dtrack.Tag) bool { return opts.owned.OwnsTag(o.Name) },
+			func(o dtrack.Tag) string { return o.Name })
+	}
 	for _, o := range remove {
 		log.Printf("apply tags: remove tag %v", o)
 
-		_, err := client.DeleteTag(ctx, policy.UUID, o.Name)
-		if err != nil {
-			if dependencytrack.IsNotFound(err) {
-				log.Printf("WARN: apply tags: remove tag: not found %v", o)
+		if !opts.dryRun {
+			if _, err := client.DeleteTag(ctx, policy.UUID, o.Name); err != nil {
+				if dependencytrack.IsNotFound(err) {
+					log.Printf("WARN: apply tags: remove tag: not found %v", o)
 
-				continue
+					continue
+				}
+				return removed, added, err
 			}
-			return err
 		}
+		removed = append(removed, o)
 	}
 	for _, o := range add {
 		log.Printf("apply tags: add tag %v", o)
 
-		_, err := client.AddTag(ctx, policy.UUID, o.Name)
-		if err != nil {
-			if dependencytrack.IsNotFound(err) {
-				log.Printf("WARN: apply tags: add tag: not found %v", o)
+		if !opts.dryRun {
+			if _, err := client.AddTag(ctx, policy.UUID, o.Name); err != nil {
+				if dependencytrack.IsNotFound(err) {
+					log.Printf("WARN: apply tags: add tag: not found %v", o)
 
-				continue
+					continue
+				}
+				return removed, added, err
 			}
-			return err
 		}
+		added = append(added, o)
 	}
-	return nil
+	return removed, added, nil
 }
 
-func applyProjects(ctx context.Context, client dependencytrack.DependencyTrackClient, policy dtrack.Policy, projectUUIDs []uuid.UUID) error {
+func applyProjects(ctx context.Context, client dependencytrack.DependencyTrackClient, policy dtrack.Policy, projectUUIDs []uuid.UUID, opts applyOptions) (removed, added []uuid.UUID, err error) {
 	currentProjectUUIDs := []uuid.UUID{}
 	for _, p := range policy.Projects {
 		currentProjectUUIDs = append(currentProjectUUIDs, p.UUID)
 	}
 
 	remove, add := compareUUIDs(currentProjectUUIDs, projectUUIDs)
+	if opts.owned != nil {
+		remove = keepOwned("project", remove, opts.owned.OwnsProject, uuid.UUID.String)
+	}
 	for _, o := range remove {
 		log.Printf("apply projects: remove project %s", o)
 
-		_, err := client.DeleteProject(ctx, policy.UUID, o)
-		if err != nil {
-			return err
+		if !opts.dryRun {
+			if _, err := client.DeleteProject(ctx, policy.UUID, o); err != nil {
+				return removed, added, err
+			}
 		}
+		removed = append(removed, o)
 	}
 	for _, o := range add {
 		log.Printf("apply projects: add project %s", o)
 
-		_, err := client.AddProject(ctx, policy.UUID, o)
-		if err != nil {
-			return err
+		if !opts.dryRun {
+			if _, err := client.AddProject(ctx, policy.UUID, o); err != nil {
+				return removed, added, err
+			}
 		}
+		added = append(added, o)
 	}
-	return nil
+	return removed, added, nil
 }
 
-func applyPolicyConditions(ctx context.Context, client dependencytrack.DependencyTrackClient, policy dtrack.Policy, conditions []dtrack.PolicyCondition) error {
+// applyPolicyConditions removes and adds policy conditions. When more policy
+// conditions than maxPolicyConditionRemovals would be removed, for example
+// because the KEV catalog is unexpectedly empty, nothing is removed without
+// force and an error is returned after the additions. In dry-run mode the
+// removals are only warned about.
+// Up to opts.concurrency conditions are removed or added at a time, and a
+// failing condition does not stop the others; the errors are joined.
+func applyPolicyConditions(ctx context.Context, client dependencytrack.DependencyTrackClient, policy dtrack.Policy, conditions []dtrack.PolicyCondition, opts applyOptions) (removed, added []dtrack.PolicyCondition, err error) {
 	remove, add := comparePolicyConditions(policy.PolicyConditions, conditions)
-	for _, o := range remove {
+	if opts.owned != nil {
+		remove = keepOwned("policyCondition", remove,
+			func(o dtrack.PolicyCondition) bool { return opts.owned.OwnsPolicyCondition(statePolicyCondition(o)) },
+			func(o dtrack.PolicyCondition) string { return o.Value })
+	}
+
+	var errTooManyRemovals error
+	if opts.maxPolicyConditionRemovals.Exceeded(len(remove), len(policy.PolicyConditions)) && !opts.force {
+		errTooManyRemovals = fmt.Errorf("refusing to remove %d of %d policy conditions: max-policy-condition-removals is %s, use --force to remove them", len(remove), len(policy.PolicyConditions), opts.maxPolicyConditionRemovals)
+		log.Printf("WARN: apply policyConditions: %v", errTooManyRemovals)
+
+		// in dry-run mode the removals are still planned, so that they are
+		// reported as changes instead of hidden behind the error
+		if opts.dryRun {
+			errTooManyRemovals = nil
+		} else {
+			remove = nil
+		}
+	}
+
+	removed, removeErr := runConcurrently(remove, opts.concurrency, func(o dtrack.PolicyCondition) error {
 		log.Printf("apply policyConditions: remove policyCondition %s", o.Value)
 
+		if opts.dryRun {
+			return nil
+		}
 		if err := client.DeletePolicyCondition(ctx, o.UUID); err != nil {
-			return err
+			return fmt.Errorf("remove policyCondition %s: %w", o.Value, err)
 		}
-	}
-	for _, o := range add {
+		return nil
+	})
+	added, addErr := runConcurrently(add, opts.concurrency, func(o dtrack.PolicyCondition) error {
 		log.Printf("apply policyConditions: add policyCondition %s", o.Value)
 
-		_, err := client.CreatePolicyCondition(ctx, policy.UUID, o)
-		if err != nil {
-			return err
+		if opts.dryRun {
+			return nil
 		}
-	}
-	return nil
+		if _, err := client.CreatePolicyCondition(ctx, policy.UUID, o); err != nil {
+			return fmt.Errorf("add policyCondition %s: %w", o.Value, err)
+		}
+		return nil
+	})
+
+	return removed, added, errors.Join(removeErr, addErr, errTooManyRemovals)
 }
 
 func desierdPolicy(policyName, operator, violationState string) dtrack.Policy {
@@ -247,33 +760,97 @@ func desierdTags(tagSlice []string) []dtrack.Tag {
 	return tags
 }
 
-func desierdProjectUUIDs(ctx context.Context, client dependencytrack.DependencyTrackClient, projectNameVersions []string) (uuids []uuid.UUID, err error) {
+// desierdProjectUUIDs resolves the project selectors of a policy. Name
+// selectors are looked up by name, the other selectors are matched against
+// all projects, which are fetched once when needed. Only active root projects
+// are selected unless includeChildren or includeInactive is set, and with
+// includeChildren the descendants of the selected projects are added.
+// Projects matching an exclusion are left out. The selectors matching no
+// project are returned as unresolved.
+func desierdProjectUUIDs(ctx context.Context, client dependencytrack.DependencyTrackClient, projectSelectors []string, includeChildren, includeInactive bool) (uuids []uuid.UUID, unresolved []string, err error) {
+	includes := []dependencytrack.ProjectSelector{}
+	excludes := []dependencytrack.ProjectSelector{}
+	for _, s := range projectSelectors {
+		selector, err := dependencytrack.ParseProjectSelector(s)
+		if err != nil {
+			return uuids, unresolved, err
+		}
+		if selector.Exclude {
+			excludes = append(excludes, selector)
+		} else {
+			includes = append(includes, selector)
+		}
+	}
+
+	inScope := func(p dtrack.Project) bool {
+		return (p.Active || includeInactive) && (p.ParentRef == nil || includeChildren)
+	}
+
+	var allProjects []dtrack.Project
+	getAllProjects := func() ([]dtrack.Project, error) {
+		if allProjects != nil {
+			return allProjects, nil
+		}
+		pp, err := client.GetProjects(ctx)
+		if err != nil {
+			return nil, err
+		}
+		allProjects = pp
+		return allProjects, nil
+	}
+
 	projects := []dtrack.Project{}
-	for _, nv := range projectNameVersions {
-		projectNameVersion := strings.SplitN(nv, ":", 2)
-		if len(projectNameVersion) == 2 {
-			p, err := client.GetProjectForNameVersion(ctx, projectNameVersion[0], projectNameVersion[1], true, true)
+	for _, selector := range includes {
+		switch {
+		case selector.Kind == dependencytrack.ProjectSelectorKindName && selector.Version != "":
+			p, err := client.GetProjectForNameVersion(ctx, selector.Value, selector.Version, !includeInactive, !includeChildren)
 			if err != nil {
 				if dependencytrack.IsNotFound(err) {
-					log.Printf("WARN: desierdProjectUUIDs: GetProjectForNameVersion: project version not found %q", nv)
+					log.Printf("WARN: desierdProjectUUIDs: GetProjectForNameVersion: project version not found %q", selector)
+					unresolved = append(unresolved, selector.String())
 
 					continue
 				}
-				return uuids, err
+				return uuids, unresolved, err
 			}
 			projects = append(projects, p)
-		} else {
-			pp, err := client.GetProjectsForName(ctx, projectNameVersion[0], true, true)
+		case selector.Kind == dependencytrack.ProjectSelectorKindName:
+			pp, err := client.GetProjectsForName(ctx, selector.Value, !includeInactive, !includeChildren)
 			if err != nil {
 				if dependencytrack.IsNotFound(err) {
-					log.Printf("WARN: desierdProjectUUIDs: GetProjectsForName: project not found %q", nv)
+					log.Printf("WARN: desierdProjectUUIDs: GetProjectsForName: project not found %q", selector)
+					unresolved = append(unresolved, selector.String())
 
 					continue
 				}
-				return uuids, err
+				return uuids, unresolved, err
 			}
 			projects = append(projects, pp...)
+		default:
+			pp, err := getAllProjects()
+			if err != nil {
+				return uuids, unresolved, err
+			}
+			matched := 0
+			for _, p := range pp {
+				if inScope(p) && selector.Match(p) {
+					projects = append(projects, p)
+					matched++
+				}
+			}
+			if matched == 0 {
+				log.Printf("WARN: desierdProjectUUIDs: no project matches %q", selector)
+				unresolved = append(unresolved, selector.String())
+			}
+		}
+	}
+
+	if includeChildren && len(projects) > 0 {
+		pp, err := getAllProjects()
+		if err != nil {
+			return uuids, unresolved, err
 		}
+		projects = append(projects, descendants(projects, pp, includeInactive)...)
 	}
 
 	seen := make(map[uuid.UUID]bool)
@@ -282,10 +859,102 @@ func desierdProjectUUIDs(ctx context.Context, client dependencytrack.DependencyT
 			continue
 		}
 		seen[project.UUID] = true
+
+		if excluded(project, excludes) {
+			log.Printf("desierdProjectUUIDs: exclude project %s:%s", project.Name, project.Version)
+
+			continue
+		}
 		uuids = append(uuids, project.UUID)
 	}
 
-	return uuids, nil
+	return uuids, unresolved, nil
+}
+
+// protectProjects adds the current projects of the policy matching an
+// unresolved selector to projectUUIDs, so that a project is never removed
+// because its lookup failed in this run.
+func protectProjects(current []dtrack.Project, projectUUIDs []uuid.UUID, unresolved []string) ([]uuid.UUID, error) {
+	if len(unresolved) == 0 {
+		return projectUUIDs, nil
+	}
+
+	selectors := []dependencytrack.ProjectSelector{}
+	for _, s := range unresolved {
+		selector, err := dependencytrack.ParseProjectSelector(s)
+		if err != nil {
+			return projectUUIDs, err
+		}
+		selectors = append(selectors, selector)
+	}
+
+	desierd := make(map[uuid.UUID]bool)
+	for _, u := range projectUUIDs {
+		desierd[u] = true
+	}
+
+	for _, p := range current {
+		if desierd[p.UUID] {
+			continue
+		}
+		for _, selector := range selectors {
+			if selector.Match(p) {
+				log.Printf("WARN: apply projects: keep project %s:%s as %q matches no project", p.Name, p.Version, selector)
+
+				projectUUIDs = append(projectUUIDs, p.UUID)
+				desierd[p.UUID] = true
+				break
+			}
+		}
+	}
+
+	return projectUUIDs, nil
+}
+
+// descendants returns the children of the parents in all, recursively.
+// Inactive children are left out unless includeInactive is set, but their
+// children are still visited.
+func descendants(parents, all []dtrack.Project, includeInactive bool) (pp []dtrack.Project) {
+	children := make(map[uuid.UUID][]dtrack.Project)
+	for _, p := range all {
+		if p.ParentRef != nil {
+			children[p.ParentRef.UUID] = append(children[p.ParentRef.UUID], p)
+		}
+	}
+
+	visited := make(map[uuid.UUID]bool)
+	queue := []uuid.UUID{}
+	for _, p := range parents {
+		visited[p.UUID] = true
+		queue = append(queue, p.UUID)
+	}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for _, child := range children[parent] {
+			if visited[child.UUID] {
+				continue
+			}
+			visited[child.UUID] = true
+			queue = append(queue, child.UUID)
+
+			if child.Active || includeInactive {
+				pp = append(pp, child)
+			}
+		}
+	}
+
+	return pp
+}
+
+func excluded(project dtrack.Project, excludes []dependencytrack.ProjectSelector) bool {
+	for _, selector := range excludes {
+		if selector.Match(project) {
+			return true
+		}
+	}
+	return false
 }
 
 func desierdPolicyConditions(cves []string) (conds []dtrack.PolicyCondition) {
@@ -353,23 +1022,44 @@ func compareUUIDs(aa, bb []uuid.UUID) (remove, add []uuid.UUID) {
 	return remove, add
 }
 
+type policyConditionKey struct {
+	subject  dtrack.PolicyConditionSubject
+	operator dtrack.PolicyConditionOperator
+	value    string
+}
+
+func keyOf(c dtrack.PolicyCondition) policyConditionKey {
+	return policyConditionKey{subject: c.Subject, operator: c.Operator, value: c.Value}
+}
+
+// comparePolicyConditions compares the policy conditions by subject, operator
+// and value. Duplicates of a current condition are removed.
 func comparePolicyConditions(aa, bb []dtrack.PolicyCondition) (removed, added []dtrack.PolicyCondition) {
-	aaMap := make(map[string]dtrack.PolicyCondition)
+	aaMap := make(map[policyConditionKey]dtrack.PolicyCondition)
 	for _, a := range aa {
-		aaMap[a.Value] = a
+		if _, ok := aaMap[keyOf(a)]; ok {
+			log.Printf("comparePolicyConditions: duplicate policyCondition %s %s %s", a.Subject, a.Operator, a.Value)
+
+			removed = append(removed, a)
+			continue
+		}
+		aaMap[keyOf(a)] = a
 	}
 
 	for _, b := range bb {
-		_, ok := aaMap[b.Value]
+		_, ok := aaMap[keyOf(b)]
 		if ok {
-			delete(aaMap, b.Value)
+			delete(aaMap, keyOf(b))
 			continue
 		}
 		added = append(added, b)
 	}
 
-	for _, a := range aaMap {
-		removed = append(removed, a)
+	for _, a := range aa {
+		if o, ok := aaMap[keyOf(a)]; ok && o.UUID == a.UUID {
+			removed = append(removed, a)
+			delete(aaMap, keyOf(a))
+		}
 	}
 
 	return removed, added
@@ -0,0 +1,935 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"github.com/takumakume/kev-to-dependencytrack/config"
+	"github.com/takumakume/kev-to-dependencytrack/dependencytrack"
+	"github.com/takumakume/kev-to-dependencytrack/kev"
+	"github.com/takumakume/kev-to-dependencytrack/mock"
+	"github.com/takumakume/kev-to-dependencytrack/state"
+)
+
+func Test_run_dryRun(t *testing.T) {
+	policyUUID := uuid.New()
+	projectUUID := uuid.New()
+	removedProjectUUID := uuid.New()
+
+	tests := []struct {
+		name       string
+		mockExpect func(m *mock.MockDependencyTrackClient)
+		want       result
+	}{
+		{
+			name: "policy not found",
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetPolicyForName(gomock.Any(), "kev").Return(dtrack.Policy{}, dependencytrack.ErrPolicyNotFound)
+				m.EXPECT().GetProjectsForName(gomock.Any(), "app", true, true).Return([]dtrack.Project{{UUID: projectUUID}}, nil)
+			},
+			want: result{
+				PolicyName:            "kev",
+				PolicyCreated:         true,
+				TagsAdded:             []string{"tag1"},
+				ProjectsAdded:         []uuid.UUID{projectUUID},
+				PolicyConditionsAdded: []string{"CVE-2023-0001", "CVE-2023-0002"},
+				PolicyConditionCount:  2,
+			},
+		},
+		{
+			name: "policy found",
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetPolicyForName(gomock.Any(), "kev").Return(dtrack.Policy{
+					UUID:           policyUUID,
+					Name:           "kev",
+					Operator:       dtrack.PolicyOperatorAll,
+					ViolationState: dtrack.PolicyViolationStateWarn,
+					Tags:           []dtrack.Tag{{Name: "tag1"}, {Name: "tag2"}},
+					Projects:       []dtrack.Project{{UUID: removedProjectUUID}},
+					PolicyConditions: []dtrack.PolicyCondition{
+						{UUID: uuid.New(), Subject: dtrack.PolicyConditionSubjectVulnerabilityID, Operator: dtrack.PolicyConditionOperatorIs, Value: "CVE-2023-0001"},
+						{UUID: uuid.New(), Subject: dtrack.PolicyConditionSubjectVulnerabilityID, Operator: dtrack.PolicyConditionOperatorIs, Value: "CVE-2022-0001"},
+					},
+				}, nil)
+				m.EXPECT().NeedsUpdatePolicy(gomock.Any(), gomock.Any()).Return(true)
+				m.EXPECT().GetProjectsForName(gomock.Any(), "app", true, true).Return([]dtrack.Project{{UUID: projectUUID}}, nil)
+			},
+			want: result{
+				PolicyName:              "kev",
+				PolicyUpdated:           true,
+				TagsRemoved:             []string{"tag2"},
+				ProjectsAdded:           []uuid.UUID{projectUUID},
+				ProjectsRemoved:         []uuid.UUID{removedProjectUUID},
+				PolicyConditionsAdded:   []string{"CVE-2023-0002"},
+				PolicyConditionsRemoved: []string{"CVE-2022-0001"},
+				PolicyConditionCount:    2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// any call to a mutating method is reported as unexpected by gomock
+			m := mock.NewMockDependencyTrackClient(ctrl)
+			tt.mockExpect(m)
+
+			c := config.New("http://127.0.0.1:8081/", "api-key", []config.Policy{
+				config.NewPolicy("kev", "ANY", "FAIL", []string{"app"}, []string{"tag1"}, config.Filter{CveIDRegex: "^CVE-2023-"}),
+			}, true)
+			catalog := &kev.Catalog{
+				Vulnerabilities: []kev.Vulnerability{
+					{CveID: "CVE-2023-0001"},
+					{CveID: "CVE-2023-0002"},
+					{CveID: "CVE-2021-0001"},
+				},
+			}
+			got, err := run(context.Background(), m, c, catalog)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if len(got) != 1 || !reflect.DeepEqual(*got[0], tt.want) {
+				t.Errorf("run() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applyPolicyConditions_maxPolicyConditionRemovals(t *testing.T) {
+	policyUUID := uuid.New()
+	policy := dtrack.Policy{UUID: policyUUID}
+	for _, cve := range []string{"CVE-2023-0001", "CVE-2023-0002", "CVE-2023-0003", "CVE-2023-0004"} {
+		policy.PolicyConditions = append(policy.PolicyConditions, dtrack.PolicyCondition{
+			UUID:     uuid.New(),
+			Subject:  dtrack.PolicyConditionSubjectVulnerabilityID,
+			Operator: dtrack.PolicyConditionOperatorIs,
+			Value:    cve,
+		})
+	}
+	added := dtrack.PolicyCondition{
+		Subject:  dtrack.PolicyConditionSubjectVulnerabilityID,
+		Operator: dtrack.PolicyConditionOperatorIs,
+		Value:    "CVE-2023-0005",
+	}
+
+	tests := []struct {
+		name        string
+		threshold   string
+		force       bool
+		dryRun      bool
+		wantRemoved int
+		wantErr     bool
+	}{
+		{
+			name:        "not exceeded",
+			threshold:   "100%",
+			wantRemoved: 4,
+			wantErr:     false,
+		},
+		{
+			name:        "exceeded",
+			threshold:   "50%",
+			wantRemoved: 0,
+			wantErr:     true,
+		},
+		{
+			name:        "exceeded with force",
+			threshold:   "3",
+			force:       true,
+			wantRemoved: 4,
+			wantErr:     false,
+		},
+		{
+			name:        "exceeded in dry run",
+			threshold:   "50%",
+			dryRun:      true,
+			wantRemoved: 4,
+			wantErr:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mock.NewMockDependencyTrackClient(ctrl)
+			if !tt.dryRun {
+				m.EXPECT().DeletePolicyCondition(gomock.Any(), gomock.Any()).Return(nil).Times(tt.wantRemoved)
+				m.EXPECT().CreatePolicyCondition(gomock.Any(), policyUUID, added).Return(added, nil)
+			}
+
+			threshold, err := config.ParseThreshold(tt.threshold)
+			if err != nil {
+				t.Fatalf("ParseThreshold() error = %v", err)
+			}
+			opts := applyOptions{maxPolicyConditionRemovals: threshold, force: tt.force, dryRun: tt.dryRun}
+
+			removed, gotAdded, err := applyPolicyConditions(context.Background(), m, policy, []dtrack.PolicyCondition{added}, opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("applyPolicyConditions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(removed) != tt.wantRemoved {
+				t.Errorf("applyPolicyConditions() removed = %d, want %d", len(removed), tt.wantRemoved)
+			}
+			if len(gotAdded) != 1 {
+				t.Errorf("applyPolicyConditions() added = %d, want 1", len(gotAdded))
+			}
+		})
+	}
+}
+
+func Test_applyPolicyConditions_concurrency(t *testing.T) {
+	policyUUID := uuid.New()
+	policy := dtrack.Policy{UUID: policyUUID}
+	failingRemove := uuid.New()
+	policy.PolicyConditions = []dtrack.PolicyCondition{
+		{UUID: uuid.New(), Subject: dtrack.PolicyConditionSubjectVulnerabilityID, Operator: dtrack.PolicyConditionOperatorIs, Value: "CVE-2023-0001"},
+		{UUID: failingRemove, Subject: dtrack.PolicyConditionSubjectVulnerabilityID, Operator: dtrack.PolicyConditionOperatorIs, Value: "CVE-2023-0002"},
+	}
+	conditions := []dtrack.PolicyCondition{}
+	for _, cve := range []string{"CVE-2023-0003", "CVE-2023-0004", "CVE-2023-0005"} {
+		conditions = append(conditions, dtrack.PolicyCondition{
+			Subject:  dtrack.PolicyConditionSubjectVulnerabilityID,
+			Operator: dtrack.PolicyConditionOperatorIs,
+			Value:    cve,
+		})
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockDependencyTrackClient(ctrl)
+	m.EXPECT().DeletePolicyCondition(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, u uuid.UUID) error {
+		if u == failingRemove {
+			return errors.New("remove error")
+		}
+		return nil
+	}).Times(2)
+	m.EXPECT().CreatePolicyCondition(gomock.Any(), policyUUID, gomock.Any()).DoAndReturn(func(_ context.Context, _ uuid.UUID, c dtrack.PolicyCondition) (dtrack.PolicyCondition, error) {
+		if c.Value == "CVE-2023-0004" {
+			return dtrack.PolicyCondition{}, errors.New("add error")
+		}
+		return c, nil
+	}).Times(3)
+
+	opts := applyOptions{concurrency: 2}
+	removed, added, err := applyPolicyConditions(context.Background(), m, policy, conditions, opts)
+	if err == nil || !strings.Contains(err.Error(), "remove error") || !strings.Contains(err.Error(), "add error") {
+		t.Errorf("applyPolicyConditions() error = %v, want both errors", err)
+	}
+	if len(removed) != 1 || removed[0].Value != "CVE-2023-0001" {
+		t.Errorf("applyPolicyConditions() removed = %+v, want CVE-2023-0001", removed)
+	}
+	if len(added) != 2 || added[0].Value != "CVE-2023-0003" || added[1].Value != "CVE-2023-0005" {
+		t.Errorf("applyPolicyConditions() added = %+v, want CVE-2023-0003 and CVE-2023-0005", added)
+	}
+}
+
+func Test_desierdProjectUUIDs(t *testing.T) {
+	app := dtrack.Project{UUID: uuid.New(), Name: "app", Version: "1.0", Active: true, Classifier: "APPLICATION"}
+	payments := dtrack.Project{UUID: uuid.New(), Name: "payments-api", Active: true, Classifier: "APPLICATION", Tags: []dtrack.Tag{{Name: "production"}}}
+	legacy := dtrack.Project{UUID: uuid.New(), Name: "payments-legacy", Active: true, Classifier: "APPLICATION"}
+	library := dtrack.Project{UUID: uuid.New(), Name: "payments-lib", Active: true, Classifier: "LIBRARY", Tags: []dtrack.Tag{{Name: "production"}}}
+	inactive := dtrack.Project{UUID: uuid.New(), Name: "payments-old", Active: false, Classifier: "APPLICATION"}
+	child := dtrack.Project{UUID: uuid.New(), Name: "payments-child", Active: true, Classifier: "APPLICATION", ParentRef: &dtrack.ParentRef{UUID: payments.UUID}}
+	inactiveChild := dtrack.Project{UUID: uuid.New(), Name: "payments-child-old", Active: false, ParentRef: &dtrack.ParentRef{UUID: child.UUID}}
+	grandchild := dtrack.Project{UUID: uuid.New(), Name: "payments-grandchild", Active: true, ParentRef: &dtrack.ParentRef{UUID: inactiveChild.UUID}}
+	all := []dtrack.Project{app, payments, legacy, library, inactive, child, inactiveChild, grandchild}
+
+	tests := []struct {
+		name            string
+		selectors       []string
+		includeChildren bool
+		includeInactive bool
+		mockExpect      func(m *mock.MockDependencyTrackClient)
+		want            []uuid.UUID
+		wantUnresolved  []string
+	}{
+		{
+			name:      "name selectors are looked up by name",
+			selectors: []string{"app", "app:1.0", "missing"},
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetProjectsForName(gomock.Any(), "app", true, true).Return([]dtrack.Project{app}, nil)
+				m.EXPECT().GetProjectForNameVersion(gomock.Any(), "app", "1.0", true, true).Return(app, nil)
+				m.EXPECT().GetProjectsForName(gomock.Any(), "missing", true, true).Return(nil, dependencytrack.ErrProjectNotFound)
+			},
+			want:           []uuid.UUID{app.UUID},
+			wantUnresolved: []string{"missing"},
+		},
+		{
+			name:      "selectors matching no project are unresolved",
+			selectors: []string{"tag:staging", "regex:^payments-old$", "!tag:missing"},
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetProjects(gomock.Any()).Return(all, nil).Times(1)
+			},
+			wantUnresolved: []string{"tag:staging", "regex:^payments-old$"},
+		},
+		{
+			name:      "tag, regex and classifier selectors match active root projects",
+			selectors: []string{"regex:^payments-", "tag:production", "classifier:application"},
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetProjects(gomock.Any()).Return(all, nil).Times(1)
+			},
+			want: []uuid.UUID{payments.UUID, legacy.UUID, library.UUID, app.UUID},
+		},
+		{
+			name:      "exclusions",
+			selectors: []string{"regex:^payments-", "!payments-legacy", "!classifier:LIBRARY"},
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetProjects(gomock.Any()).Return(all, nil).Times(1)
+			},
+			want: []uuid.UUID{payments.UUID},
+		},
+		{
+			name:            "include children",
+			selectors:       []string{"payments-api"},
+			includeChildren: true,
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetProjectsForName(gomock.Any(), "payments-api", true, false).Return([]dtrack.Project{payments}, nil)
+				m.EXPECT().GetProjects(gomock.Any()).Return(all, nil).Times(1)
+			},
+			want: []uuid.UUID{payments.UUID, child.UUID, grandchild.UUID},
+		},
+		{
+			name:            "include children and inactive projects",
+			selectors:       []string{"regex:^payments-(api|old)$"},
+			includeChildren: true,
+			includeInactive: true,
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetProjects(gomock.Any()).Return(all, nil).Times(1)
+			},
+			want: []uuid.UUID{payments.UUID, inactive.UUID, child.UUID, inactiveChild.UUID, grandchild.UUID},
+		},
+		{
+			name:            "selectors match child projects with include children",
+			selectors:       []string{"regex:-child$"},
+			includeChildren: true,
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetProjects(gomock.Any()).Return(all, nil).Times(1)
+			},
+			want: []uuid.UUID{child.UUID, grandchild.UUID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mock.NewMockDependencyTrackClient(ctrl)
+			tt.mockExpect(m)
+
+			got, gotUnresolved, err := desierdProjectUUIDs(context.Background(), m, tt.selectors, tt.includeChildren, tt.includeInactive)
+			if err != nil {
+				t.Fatalf("desierdProjectUUIDs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("desierdProjectUUIDs() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotUnresolved, tt.wantUnresolved) {
+				t.Errorf("desierdProjectUUIDs() unresolved = %v, want %v", gotUnresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func Test_protectProjects(t *testing.T) {
+	app := dtrack.Project{UUID: uuid.New(), Name: "app", Version: "1.0"}
+	api := dtrack.Project{UUID: uuid.New(), Name: "payments-api"}
+	other := dtrack.Project{UUID: uuid.New(), Name: "other"}
+	desierd := uuid.New()
+
+	tests := []struct {
+		name         string
+		projectUUIDs []uuid.UUID
+		unresolved   []string
+		want         []uuid.UUID
+	}{
+		{
+			name:         "no unresolved selector",
+			projectUUIDs: []uuid.UUID{desierd},
+			want:         []uuid.UUID{desierd},
+		},
+		{
+			name:         "projects matching an unresolved selector are kept",
+			projectUUIDs: []uuid.UUID{desierd},
+			unresolved:   []string{"app:1.0", "regex:^payments-"},
+			want:         []uuid.UUID{desierd, app.UUID, api.UUID},
+		},
+		{
+			name:         "desierd projects are not duplicated",
+			projectUUIDs: []uuid.UUID{app.UUID},
+			unresolved:   []string{"app"},
+			want:         []uuid.UUID{app.UUID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protectProjects([]dtrack.Project{app, api, other}, tt.projectUUIDs, tt.unresolved)
+			if err != nil {
+				t.Fatalf("protectProjects() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("protectProjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_run_strict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// no other call is expected: no policy is touched, including the one
+	// whose selectors resolve
+	m := mock.NewMockDependencyTrackClient(ctrl)
+	m.EXPECT().GetProjectsForName(gomock.Any(), "app", true, true).Return([]dtrack.Project{{UUID: uuid.New(), Name: "app"}}, nil)
+	m.EXPECT().GetProjectsForName(gomock.Any(), "missing", true, true).Return(nil, dependencytrack.ErrProjectNotFound)
+
+	c := config.New("http://127.0.0.1:8081/", "api-key", []config.Policy{
+		config.NewPolicy("kev-app", "ANY", "FAIL", []string{"app"}, nil, config.Filter{}),
+		config.NewPolicy("kev-missing", "ANY", "FAIL", []string{"missing"}, nil, config.Filter{}),
+	}, false)
+	c.Strict = true
+	results, err := run(context.Background(), m, c, &kev.Catalog{})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("run() error = %v, want unresolved selector error", err)
+	}
+	if len(results) != 1 || results[0].PolicyName != "kev-missing" || results[0].Error == "" {
+		t.Errorf("run() results = %+v, want the failed policy", results)
+	}
+}
+
+func Test_run_managed(t *testing.T) {
+	policyUUID := uuid.New()
+	manualProject := dtrack.Project{UUID: uuid.New(), Name: "manual"}
+	ownedProject := dtrack.Project{UUID: uuid.New(), Name: "owned"}
+	condition := func(cve string) dtrack.PolicyCondition {
+		return dtrack.PolicyCondition{
+			UUID:     uuid.New(),
+			Subject:  dtrack.PolicyConditionSubjectVulnerabilityID,
+			Operator: dtrack.PolicyConditionOperatorIs,
+			Value:    cve,
+		}
+	}
+	manualCondition := condition("CVE-2020-0001")
+	ownedCondition := condition("CVE-2020-0002")
+	// a duplicate of a desired condition is removed and the kept one stays owned
+	keptCondition := condition("CVE-2023-0001")
+	duplicateCondition := condition("CVE-2023-0001")
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	st := state.New()
+	owned := st.Policy("kev")
+	owned.UpdateTags(nil, []string{"owned"})
+	owned.UpdateProjects(nil, []uuid.UUID{ownedProject.UUID})
+	owned.UpdatePolicyConditions(nil, statePolicyConditions([]dtrack.PolicyCondition{ownedCondition, keptCondition}))
+	if err := st.Save(stateFile); err != nil {
+		t.Fatalf("State.Save() error = %v", err)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockDependencyTrackClient(ctrl)
+	m.EXPECT().GetPolicyForName(gomock.Any(), "kev").Return(dtrack.Policy{
+		UUID:             policyUUID,
+		Name:             "kev",
+		Operator:         dtrack.PolicyOperatorAny,
+		ViolationState:   dtrack.PolicyViolationStateFail,
+		Tags:             []dtrack.Tag{{Name: "manual"}, {Name: "owned"}},
+		Projects:         []dtrack.Project{manualProject, ownedProject},
+		PolicyConditions: []dtrack.PolicyCondition{manualCondition, ownedCondition, keptCondition, duplicateCondition},
+	}, nil)
+	m.EXPECT().NeedsUpdatePolicy(gomock.Any(), gomock.Any()).Return(false)
+	m.EXPECT().DeleteTag(gomock.Any(), policyUUID, "owned").Return(dtrack.Policy{}, nil)
+	m.EXPECT().AddTag(gomock.Any(), policyUUID, "tag1").Return(dtrack.Policy{}, nil)
+	m.EXPECT().DeleteProject(gomock.Any(), policyUUID, ownedProject.UUID).Return(dtrack.Policy{}, nil)
+	m.EXPECT().DeletePolicyCondition(gomock.Any(), ownedCondition.UUID).Return(nil)
+	m.EXPECT().DeletePolicyCondition(gomock.Any(), duplicateCondition.UUID).Return(nil)
+
+	c := config.New("http://127.0.0.1:8081/", "api-key", []config.Policy{
+		config.NewPolicy("kev", "ANY", "FAIL", nil, []string{"tag1"}, config.Filter{}),
+	}, false)
+	c.MaxPolicyConditionRemovals = "100%"
+	c.StateFile = stateFile
+	c.Managed = true
+	catalog := &kev.Catalog{Vulnerabilities: []kev.Vulnerability{{CveID: "CVE-2023-0001"}}}
+	if _, err := run(context.Background(), m, c, catalog); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	got, err := state.Load(stateFile)
+	if err != nil {
+		t.Fatalf("state.Load() error = %v", err)
+	}
+	want := &state.Policy{
+		UUID:        policyUUID,
+		AppliedCVEs: []string{"CVE-2023-0001"},
+		Tags:        []string{"tag1"},
+		Projects:    []uuid.UUID{},
+		PolicyConditions: []state.PolicyCondition{
+			{Subject: "VULNERABILITY_ID", Operator: "IS", Value: "CVE-2023-0001"},
+		},
+	}
+	if !reflect.DeepEqual(got.Policy("kev"), want) {
+		t.Errorf("state = %+v, want %+v", got.Policy("kev"), want)
+	}
+}
+
+func Test_run_managed_adopt(t *testing.T) {
+	policyUUID := uuid.New()
+	project := dtrack.Project{UUID: uuid.New(), Name: "app"}
+	manualProject := dtrack.Project{UUID: uuid.New(), Name: "manual"}
+	condition := func(cve string) dtrack.PolicyCondition {
+		return dtrack.PolicyCondition{
+			UUID:     uuid.New(),
+			Subject:  dtrack.PolicyConditionSubjectVulnerabilityID,
+			Operator: dtrack.PolicyConditionOperatorIs,
+			Value:    cve,
+		}
+	}
+	// the policy was populated before the state file existed
+	populated := dtrack.Policy{
+		UUID:             policyUUID,
+		Name:             "kev",
+		Operator:         dtrack.PolicyOperatorAny,
+		ViolationState:   dtrack.PolicyViolationStateFail,
+		Tags:             []dtrack.Tag{{Name: "tag1"}, {Name: "manual"}},
+		Projects:         []dtrack.Project{project, manualProject},
+		PolicyConditions: []dtrack.PolicyCondition{condition("CVE-2023-0001"), condition("CVE-2020-0001")},
+	}
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	newConfig := func(tags []string) *config.Config {
+		c := config.New("http://127.0.0.1:8081/", "api-key", []config.Policy{
+			config.NewPolicy("kev", "ANY", "FAIL", []string{"app"}, tags, config.Filter{}),
+		}, false)
+		c.StateFile = stateFile
+		c.Managed = true
+		return c
+	}
+
+	// the first managed run removes nothing and adopts the desired entries
+	ctrl := gomock.NewController(t)
+	m := mock.NewMockDependencyTrackClient(ctrl)
+	m.EXPECT().GetProjectsForName(gomock.Any(), "app", true, true).Return([]dtrack.Project{project}, nil)
+	m.EXPECT().GetPolicyForName(gomock.Any(), "kev").Return(populated, nil)
+	m.EXPECT().NeedsUpdatePolicy(gomock.Any(), gomock.Any()).Return(false)
+	catalog := &kev.Catalog{Vulnerabilities: []kev.Vulnerability{{CveID: "CVE-2023-0001"}}}
+	if _, err := run(context.Background(), m, newConfig([]string{"tag1"}), catalog); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	ctrl.Finish()
+
+	got, err := state.Load(stateFile)
+	if err != nil {
+		t.Fatalf("state.Load() error = %v", err)
+	}
+	want := &state.Policy{
+		UUID:        policyUUID,
+		AppliedCVEs: []string{"CVE-2023-0001"},
+		Tags:        []string{"tag1"},
+		Projects:    []uuid.UUID{project.UUID},
+		PolicyConditions: []state.PolicyCondition{
+			{Subject: "VULNERABILITY_ID", Operator: "IS", Value: "CVE-2023-0001"},
+		},
+	}
+	if !reflect.DeepEqual(got.Policy("kev"), want) {
+		t.Errorf("state = %+v, want %+v", got.Policy("kev"), want)
+	}
+
+	// the adopted entries are removed once they are no longer desired
+	ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+	m = mock.NewMockDependencyTrackClient(ctrl)
+	m.EXPECT().GetProjectsForName(gomock.Any(), "app", true, true).Return([]dtrack.Project{project}, nil)
+	m.EXPECT().GetPolicyForName(gomock.Any(), "kev").Return(populated, nil)
+	m.EXPECT().NeedsUpdatePolicy(gomock.Any(), gomock.Any()).Return(false)
+	m.EXPECT().DeleteTag(gomock.Any(), policyUUID, "tag1").Return(dtrack.Policy{}, nil)
+	m.EXPECT().DeletePolicyCondition(gomock.Any(), populated.PolicyConditions[0].UUID).Return(nil)
+	m.EXPECT().CreatePolicyCondition(gomock.Any(), policyUUID, gomock.Any()).Return(dtrack.PolicyCondition{}, nil)
+	c := newConfig(nil)
+	c.MaxPolicyConditionRemovals = "100%"
+	catalog = &kev.Catalog{Vulnerabilities: []kev.Vulnerability{{CveID: "CVE-2023-0002"}}}
+	if _, err := run(context.Background(), m, c, catalog); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+}
+
+func Test_run_skipUnchanged(t *testing.T) {
+	policyUUID := uuid.New()
+	catalog := &kev.Catalog{
+		CatalogVersion:  "2023.01.01",
+		DateReleased:    "2023-01-01T00:00:00.000Z",
+		Vulnerabilities: []kev.Vulnerability{{CveID: "CVE-2023-0001"}},
+	}
+	newCatalog := &kev.Catalog{CatalogVersion: "2023.01.02", DateReleased: catalog.DateReleased, Vulnerabilities: catalog.Vulnerabilities}
+	newConfig := func(violationState string) *config.Config {
+		c := config.New("http://127.0.0.1:8081/", "api-key", []config.Policy{
+			config.NewPolicy("kev", "ANY", violationState, nil, nil, config.Filter{}),
+		}, false)
+		c.SkipUnchanged = true
+		return c
+	}
+	newAuditConfig := func() *config.Config {
+		c := newConfig("WARN")
+		c.Audit = true
+		return c
+	}
+	projectUUID := uuid.New()
+	expectSync := func(m *mock.MockDependencyTrackClient) {
+		m.EXPECT().GetPolicyForName(gomock.Any(), "kev").Return(dtrack.Policy{
+			UUID:           policyUUID,
+			Name:           "kev",
+			Operator:       dtrack.PolicyOperatorAny,
+			ViolationState: dtrack.PolicyViolationStateFail,
+			PolicyConditions: []dtrack.PolicyCondition{{
+				UUID:     uuid.New(),
+				Subject:  dtrack.PolicyConditionSubjectVulnerabilityID,
+				Operator: dtrack.PolicyConditionOperatorIs,
+				Value:    "CVE-2023-0001",
+			}},
+		}, nil)
+		m.EXPECT().NeedsUpdatePolicy(gomock.Any(), gomock.Any()).Return(false)
+	}
+
+	tests := []struct {
+		name    string
+		c       *config.Config
+		catalog *kev.Catalog
+		// lastConfig and lastCatalog describe the last successful run in the
+		// state file; the state file is empty when lastConfig is nil
+		lastConfig  *config.Config
+		lastCatalog *kev.Catalog
+		mockExpect  func(m *mock.MockDependencyTrackClient)
+		wantSkipped bool
+	}{
+		{
+			name:       "first run",
+			c:          newConfig("FAIL"),
+			catalog:    catalog,
+			mockExpect: expectSync,
+		},
+		{
+			name:        "unchanged",
+			c:           newConfig("FAIL"),
+			catalog:     catalog,
+			lastConfig:  newConfig("FAIL"),
+			lastCatalog: catalog,
+			mockExpect:  func(m *mock.MockDependencyTrackClient) {},
+			wantSkipped: true,
+		},
+		{
+			name:        "catalog changed",
+			c:           newConfig("FAIL"),
+			catalog:     newCatalog,
+			lastConfig:  newConfig("FAIL"),
+			lastCatalog: catalog,
+			mockExpect:  expectSync,
+		},
+		{
+			name:        "config changed",
+			c:           newConfig("WARN"),
+			catalog:     newCatalog,
+			lastConfig:  newConfig("FAIL"),
+			lastCatalog: newCatalog,
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				m.EXPECT().GetPolicyForName(gomock.Any(), "kev").Return(dtrack.Policy{UUID: policyUUID, Name: "kev"}, nil)
+				m.EXPECT().NeedsUpdatePolicy(gomock.Any(), gomock.Any()).Return(false)
+				m.EXPECT().CreatePolicyCondition(gomock.Any(), policyUUID, gomock.Any()).Return(dtrack.PolicyCondition{}, nil)
+			},
+		},
+		{
+			name:        "audit enabled",
+			c:           newAuditConfig(),
+			catalog:     newCatalog,
+			lastConfig:  newConfig("WARN"),
+			lastCatalog: newCatalog,
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				expectSync(m)
+				m.EXPECT().GetProjects(gomock.Any()).Return(nil, nil)
+			},
+		},
+		{
+			name:        "unchanged with audit",
+			c:           newAuditConfig(),
+			catalog:     newCatalog,
+			lastConfig:  newAuditConfig(),
+			lastCatalog: newCatalog,
+			mockExpect: func(m *mock.MockDependencyTrackClient) {
+				// the policy is skipped, but the new finding is audited
+				finding := dtrack.Finding{
+					Component:     dtrack.FindingComponent{UUID: uuid.New()},
+					Vulnerability: dtrack.FindingVulnerability{UUID: uuid.New(), VulnID: "CVE-2023-0001"},
+				}
+				m.EXPECT().GetProjects(gomock.Any()).Return([]dtrack.Project{{UUID: projectUUID, Active: true}}, nil)
+				m.EXPECT().GetFindings(gomock.Any(), projectUUID).Return([]dtrack.Finding{finding}, nil)
+				m.EXPECT().GetAnalysis(gomock.Any(), gomock.Any(), projectUUID, gomock.Any()).Return(dtrack.Analysis{}, &dtrack.APIError{StatusCode: 404})
+				m.EXPECT().RecordAnalysis(gomock.Any(), gomock.Any()).Return(dtrack.Analysis{}, nil)
+			},
+			wantSkipped: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.StateFile = filepath.Join(t.TempDir(), "state.json")
+			st := state.New()
+			if tt.lastConfig != nil {
+				configHash, err := tt.lastConfig.Hash()
+				if err != nil {
+					t.Fatalf("Config.Hash() error = %v", err)
+				}
+				st.LastApplied = &state.Applied{
+					CatalogVersion: tt.lastCatalog.CatalogVersion,
+					DateReleased:   tt.lastCatalog.DateReleased,
+					ConfigHash:     configHash,
+					AppliedAt:      time.Now().UTC(),
+				}
+				st.Policy("kev").Applied(policyUUID, tt.lastCatalog.VulnerabilitiyIDs())
+			}
+			if err := st.Save(tt.c.StateFile); err != nil {
+				t.Fatalf("State.Save() error = %v", err)
+			}
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mock.NewMockDependencyTrackClient(ctrl)
+			tt.mockExpect(m)
+
+			got, err := run(context.Background(), m, tt.c, tt.catalog)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if len(got) != 1 || got[0].Skipped != tt.wantSkipped {
+				t.Errorf("run() = %+v, want skipped %v", got, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func Test_comparePolicyConditions(t *testing.T) {
+	condition := func(operator dtrack.PolicyConditionOperator, cve string) dtrack.PolicyCondition {
+		return dtrack.PolicyCondition{
+			UUID:     uuid.New(),
+			Subject:  dtrack.PolicyConditionSubjectVulnerabilityID,
+			Operator: operator,
+			Value:    cve,
+		}
+	}
+	is1 := condition(dtrack.PolicyConditionOperatorIs, "CVE-2023-0001")
+	is1Duplicate := condition(dtrack.PolicyConditionOperatorIs, "CVE-2023-0001")
+	isNot1 := condition(dtrack.PolicyConditionOperatorIsNot, "CVE-2023-0001")
+	is2 := condition(dtrack.PolicyConditionOperatorIs, "CVE-2023-0002")
+	desierd := desierdPolicyConditions([]string{"CVE-2023-0001", "CVE-2023-0002"})
+
+	tests := []struct {
+		name        string
+		current     []dtrack.PolicyCondition
+		wantRemoved []dtrack.PolicyCondition
+		wantAdded   []dtrack.PolicyCondition
+	}{
+		{
+			name:      "empty policy",
+			current:   nil,
+			wantAdded: desierd,
+		},
+		{
+			name:    "up to date",
+			current: []dtrack.PolicyCondition{is2, is1},
+		},
+		{
+			name:        "different operator is not the same condition",
+			current:     []dtrack.PolicyCondition{isNot1, is2},
+			wantRemoved: []dtrack.PolicyCondition{isNot1},
+			wantAdded:   desierd[:1],
+		},
+		{
+			name:        "duplicates are removed",
+			current:     []dtrack.PolicyCondition{is1, is2, is1Duplicate},
+			wantRemoved: []dtrack.PolicyCondition{is1Duplicate},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotRemoved, gotAdded := comparePolicyConditions(tt.current, desierd)
+			if !reflect.DeepEqual(gotRemoved, tt.wantRemoved) {
+				t.Errorf("comparePolicyConditions() removed = %v, want %v", gotRemoved, tt.wantRemoved)
+			}
+			if !reflect.DeepEqual(gotAdded, tt.wantAdded) {
+				t.Errorf("comparePolicyConditions() added = %v, want %v", gotAdded, tt.wantAdded)
+			}
+		})
+	}
+}
+
+// withConfigFile writes content to a config file named name and sets it as
+// the config of newConfig together with the flags in set. The config file and
+// the flags are reset when the test ends.
+func withConfigFile(t *testing.T, name, content string, set map[string]interface{}) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	viper.Set("config", path)
+	viper.Set("api-key", "api-key")
+	for k, v := range set {
+		viper.Set(k, v)
+	}
+	t.Cleanup(func() {
+		viper.Set("config", nil)
+		viper.Set("api-key", nil)
+		for k := range set {
+			viper.Set(k, nil)
+		}
+		// forget the keys read from the config file by reading an empty one;
+		// viper.SetConfigType would stick to the later config files
+		empty := filepath.Join(filepath.Dir(path), "empty.yaml")
+		if err := os.WriteFile(empty, nil, 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		viper.SetConfigFile(empty)
+		if err := viper.ReadInConfig(); err != nil {
+			t.Fatalf("failed to reset config file: %v", err)
+		}
+	})
+}
+
+func Test_newConfig_filterDates(t *testing.T) {
+	withConfigFile(t, "config.yaml", `
+policies:
+  - name: KEV-WARN-OLD
+    filter:
+      date-added-until: 2023-06-30
+      due-date-since: 2023-01-01
+      due-date-until: "2023-12-31"
+`, nil)
+
+	c, err := newConfig()
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+
+	want := config.Filter{
+		DateAddedUntil: "2023-06-30",
+		DueDateSince:   "2023-01-01",
+		DueDateUntil:   "2023-12-31",
+	}
+	if got := c.Policies[0].Filter; !reflect.DeepEqual(got, want) {
+		t.Errorf("newConfig() filter = %+v, want %+v", got, want)
+	}
+}
+
+func Test_newConfig(t *testing.T) {
+	want := []config.Policy{
+		{
+			Name:           "KEV-FAIL",
+			Operator:       "ALL",
+			ViolationState: "FAIL",
+			Projects:       []string{"payments", "tag:production"},
+			Tags:           []string{"production"},
+			Filter: config.Filter{
+				VendorProjects:             []string{"Microsoft"},
+				DateAddedSince:             "90d",
+				KnownRansomwareCampaignUse: "Known",
+			},
+			IncludeChildren: true,
+		},
+		{
+			Name:           "KEV-WARN",
+			Operator:       config.DefaultPolicyOperator,
+			ViolationState: config.DefaultPolicyViolationState,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		set     map[string]interface{}
+		want    []config.Policy
+		wantErr bool
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+policies:
+  - name: KEV-FAIL
+    operator: ALL
+    violation-state: FAIL
+    projects: [payments, "tag:production"]
+    tags: [production]
+    include-children: true
+    filter:
+      vendor-projects: [Microsoft]
+      date-added-since: 90d
+      known-ransomware-campaign-use: Known
+  - name: KEV-WARN
+`,
+			want: want,
+		},
+		{
+			name: "json",
+			file: "config.json",
+			content: `{
+  "policies": [
+    {
+      "name": "KEV-FAIL",
+      "operator": "ALL",
+      "violation-state": "FAIL",
+      "projects": ["payments", "tag:production"],
+      "tags": ["production"],
+      "include-children": true,
+      "filter": {
+        "vendor-projects": ["Microsoft"],
+        "date-added-since": "90d",
+        "known-ransomware-campaign-use": "Known"
+      }
+    },
+    {"name": "KEV-WARN"}
+  ]
+}`,
+			want: want,
+		},
+		{
+			name: "policies with policy-name",
+			file: "config.yaml",
+			content: `
+policies:
+  - name: KEV-WARN
+`,
+			set:     map[string]interface{}{"policy-name": "other"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfigFile(t, tt.file, tt.content, tt.set)
+
+			c, err := newConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(c.Policies, tt.want) {
+				t.Errorf("newConfig() policies = %+v, want %+v", c.Policies, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/takumakume/kev-to-dependencytrack/metrics"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Keep running and reconcile the policies periodically",
+	Long: `Keep running and reconcile the policies once on start and then every --interval,
+or on the cron expression given by --schedule.
+On SIGINT or SIGTERM the running reconciliation is completed before exiting.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newConfig()
+		if err != nil {
+			return err
+		}
+
+		// stdin can be read only once, but the catalog is read on every reconciliation
+		if viper.GetString("kev-file") == "-" {
+			return errors.New("kev-file - (stdin) cannot be used with serve")
+		}
+
+		output := viper.GetString("output")
+		if err := validateOutput(output); err != nil {
+			return err
+		}
+
+		schedule, err := newSchedule(viper.GetDuration("interval"), viper.GetString("schedule"))
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if addr := viper.GetString("metrics-addr"); addr != "" {
+			srv := newMetricsServer(addr)
+			go func() {
+				log.Printf("serving metrics on %s/metrics", addr)
+				if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Printf("ERROR: metrics server: %v", err)
+				}
+			}()
+			defer func() {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				srv.Shutdown(shutdownCtx)
+			}()
+		}
+
+		for {
+			// a running reconciliation is not canceled by a signal, so that
+			// the policy is not left half updated
+			if err := syncOnce(context.Background(), c, output, cmd.OutOrStdout()); err != nil {
+				log.Printf("ERROR: sync: %v", err)
+			}
+
+			next := schedule.Next(time.Now())
+			log.Printf("next sync at %s", next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				log.Println("shutting down")
+
+				return nil
+			case <-timer.C:
+			}
+		}
+	},
+}
+
+func init() {
+	flags := serveCmd.Flags()
+
+	flags.DurationP("interval", "", time.Hour, "Interval between reconciliations (env: DT_INTERVAL)")
+	flags.StringP("schedule", "", "", "Cron expression of the reconciliations, e.g. \"*/15 * * * *\" (env: DT_SCHEDULE)")
+	flags.StringP("metrics-addr", "", "", "Address to serve Prometheus metrics on /metrics, e.g. \":9090\" (env: DT_METRICS_ADDR)")
+
+	viper.BindPFlag("interval", flags.Lookup("interval"))
+	viper.BindPFlag("schedule", flags.Lookup("schedule"))
+	viper.BindPFlag("metrics-addr", flags.Lookup("metrics-addr"))
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+// newSchedule returns the schedule of the cron expression when given,
+// otherwise of the interval.
+func newSchedule(interval time.Duration, expr string) (cron.Schedule, error) {
+	if expr != "" {
+		return cron.ParseStandard(expr)
+	}
+
+	if interval < time.Second {
+		return nil, errors.New("interval must be at least 1s")
+	}
+
+	return cron.Every(interval), nil
+}
+
+func newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_newSchedule(t *testing.T) {
+	now := time.Date(2023, 7, 1, 12, 3, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		expr     string
+		want     time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "interval",
+			interval: time.Hour,
+			want:     time.Date(2023, 7, 1, 13, 3, 0, 0, time.UTC),
+		},
+		{
+			name:     "cron expression takes precedence",
+			interval: time.Hour,
+			expr:     "*/15 * * * *",
+			want:     time.Date(2023, 7, 1, 12, 15, 0, 0, time.UTC),
+		},
+		{
+			name:    "invalid cron expression",
+			expr:    "every 15 minutes",
+			wantErr: true,
+		},
+		{
+			name:     "too short interval",
+			interval: time.Millisecond,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newSchedule(tt.interval, tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newSchedule() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if next := got.Next(now); !next.Equal(tt.want) {
+				t.Errorf("newSchedule().Next() = %v, want %v", next, tt.want)
+			}
+		})
+	}
+}
@@ -1,32 +1,122 @@
 package config
 
-import "errors"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/takumakume/kev-to-dependencytrack/dependencytrack"
+)
+
+const (
+	DefaultPolicyOperator       = "ANY"
+	DefaultPolicyViolationState = "WARN"
+
+	// DefaultMaxPolicyConditionRemovals is empty: the limit is opt-in, so that
+	// a KEV catalog shrinking or a narrowed filter is applied as before.
+	DefaultMaxPolicyConditionRemovals = ""
+	DefaultConcurrency                = 4
+)
 
 type Config struct {
 	BaseURL string
 	APIKey  string
 
-	PolicyName           string
-	PolicyOperator       string
-	PolicyViolationState string
-	PolicyProjects       []string
-	PolicyTags           []string
+	Policies []Policy
+
+	DryRun bool
+
+	// MaxPolicyConditionRemovals is the maximum number ("10") or percentage ("10%")
+	// of the existing policy conditions of a policy removed in a run.
+	// Removing more requires Force. Empty means no limit.
+	MaxPolicyConditionRemovals string
+	Force                      bool
+
+	// Strict aborts the run before changing any policy when a project
+	// selector of a policy resolves to no project.
+	Strict bool
+
+	// Concurrency is the maximum number of policy conditions created or
+	// deleted at a time.
+	Concurrency int
+	// StateFile records the tags, projects and policy conditions added by
+	// kev-to-dependencytrack. In Managed mode only those are removed.
+	StateFile string
+	Managed   bool
+
+	// SkipUnchanged skips the run when the StateFile records a successful run
+	// with the same KEV catalog, config and CVEs of the policies.
+	SkipUnchanged bool
+
+	// Audit records the KEV entry as an analysis comment on the findings of
+	// the projects of a policy, and raises their analysis state to
+	// AuditAnalysisState when given.
+	Audit              bool
+	AuditAnalysisState string
+
+	// Retry controls the retries of the Dependency Track API calls.
+	Retry dependencytrack.RetryOptions
+}
+
+// Policy describes a Dependency Track policy managed by kev-to-dependencytrack.
+// The mapstructure tags are the keys of a policy in the config file.
+type Policy struct {
+	Name           string   `mapstructure:"name"`
+	Operator       string   `mapstructure:"operator"`
+	ViolationState string   `mapstructure:"violation-state"`
+	Projects       []string `mapstructure:"projects"`
+	Tags           []string `mapstructure:"tags"`
+	Filter         Filter   `mapstructure:"filter"`
+
+	// IncludeChildren adds the child projects of the selected projects recursively,
+	// and lets the selectors match child projects.
+	IncludeChildren bool `mapstructure:"include-children"`
+	// IncludeInactive lets the selectors match inactive projects.
+	IncludeInactive bool `mapstructure:"include-inactive"`
 }
 
 var (
 	ErrAPIKeyIsRequired     = errors.New("api-key is required")
+	ErrPolicyIsRequired     = errors.New("at least one policy is required")
 	ErrPolicyNameIsRequired = errors.New("policy-name is required")
+	ErrStateFileIsRequired  = errors.New("state-file is required by managed and skip-unchanged")
+	ErrAuditIsRequired      = errors.New("audit-analysis-state requires audit-findings")
 )
 
-func New(baseURL, apiKey, policyName, policyOperator, policyViolationState string, policyProjects, policyTags []string) *Config {
+// New returns the config of the policies with the defaults of the other settings.
+// The other settings are set on the returned config.
+func New(baseURL, apiKey string, policies []Policy, dryRun bool) *Config {
+	for i := range policies {
+		if policies[i].Operator == "" {
+			policies[i].Operator = DefaultPolicyOperator
+		}
+		if policies[i].ViolationState == "" {
+			policies[i].ViolationState = DefaultPolicyViolationState
+		}
+	}
+
 	return &Config{
-		BaseURL:              baseURL,
-		APIKey:               apiKey,
-		PolicyName:           policyName,
-		PolicyOperator:       policyOperator,
-		PolicyViolationState: policyViolationState,
-		PolicyProjects:       policyProjects,
-		PolicyTags:           policyTags,
+		BaseURL:  baseURL,
+		APIKey:   apiKey,
+		Policies: policies,
+		DryRun:   dryRun,
+
+		Concurrency: DefaultConcurrency,
+	}
+}
+
+func NewPolicy(name, operator, violationState string, projects, tags []string, filter Filter) Policy {
+	return Policy{
+		Name:           name,
+		Operator:       operator,
+		ViolationState: violationState,
+		Projects:       projects,
+		Tags:           tags,
+		Filter:         filter,
 	}
 }
 
@@ -35,9 +125,74 @@ func (c *Config) Validate() error {
 		return ErrAPIKeyIsRequired
 	}
 
-	if c.PolicyName == "" {
-		return ErrPolicyNameIsRequired
+	if _, err := ParseThreshold(c.MaxPolicyConditionRemovals); err != nil {
+		return fmt.Errorf("max-policy-condition-removals: %w", err)
+	}
+
+	if (c.Managed || c.SkipUnchanged) && c.StateFile == "" {
+		return ErrStateFileIsRequired
+	}
+
+	if err := c.Retry.Validate(); err != nil {
+		return fmt.Errorf("retry: %w", err)
+	}
+
+	if c.Concurrency < 0 {
+		return fmt.Errorf("concurrency must not be negative: %d", c.Concurrency)
+	}
+
+	if c.AuditAnalysisState != "" {
+		if !c.Audit {
+			return ErrAuditIsRequired
+		}
+		switch dtrack.AnalysisState(c.AuditAnalysisState) {
+		case dtrack.AnalysisStateExploitable, dtrack.AnalysisStateInTriage:
+		default:
+			return fmt.Errorf("audit-analysis-state: invalid value %q: expected %s or %s", c.AuditAnalysisState, dtrack.AnalysisStateExploitable, dtrack.AnalysisStateInTriage)
+		}
+	}
+
+	if len(c.Policies) == 0 {
+		return ErrPolicyIsRequired
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range c.Policies {
+		if p.Name == "" {
+			return ErrPolicyNameIsRequired
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate policy name: %s", p.Name)
+		}
+		seen[p.Name] = true
+
+		for _, project := range p.Projects {
+			if _, err := dependencytrack.ParseProjectSelector(project); err != nil {
+				return fmt.Errorf("policy %q: %w", p.Name, err)
+			}
+		}
+
+		if _, err := p.Filter.KEVFilter(time.Now()); err != nil {
+			return fmt.Errorf("policy %q: filter: %w", p.Name, err)
+		}
 	}
 
 	return nil
 }
+
+// Hash returns a digest of the settings deciding the desired state of the policies.
+func (c *Config) Hash() (string, error) {
+	buf, err := json.Marshal(struct {
+		BaseURL            string
+		Policies           []Policy
+		Managed            bool
+		Audit              bool
+		AuditAnalysisState string
+	}{c.BaseURL, c.Policies, c.Managed, c.Audit, c.AuditAnalysisState})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -1,16 +1,25 @@
 package config
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"github.com/takumakume/kev-to-dependencytrack/dependencytrack"
+)
 
 func TestConfig_Validate(t *testing.T) {
 	type fields struct {
-		BaseURL              string
-		APIKey               string
-		PolicyName           string
-		PolicyOperator       string
-		PolicyViolationState string
-		PolicyProjects       []string
-		PolicyTags           []string
+		BaseURL                    string
+		APIKey                     string
+		Policies                   []Policy
+		MaxPolicyConditionRemovals string
+		Concurrency                int
+		StateFile                  string
+		Managed                    bool
+		SkipUnchanged              bool
+		Audit                      bool
+		AuditAnalysisState         string
+		Retry                      *dependencytrack.RetryOptions
 	}
 	tests := []struct {
 		name    string
@@ -20,27 +29,184 @@ func TestConfig_Validate(t *testing.T) {
 		{
 			name: "valid config",
 			fields: fields{
-				BaseURL:    "https://example.com",
-				APIKey:     "api-key",
-				PolicyName: "policy-name",
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: "policy-name"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid config with multiple policies",
+			fields: fields{
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: "policy-name-1"}, {Name: "policy-name-2"}},
 			},
 			wantErr: false,
 		},
 		{
 			name: "missing API key",
 			fields: fields{
-				BaseURL:    "https://example.com",
-				APIKey:     "",
-				PolicyName: "policy-name",
+				BaseURL:  "https://example.com",
+				APIKey:   "",
+				Policies: []Policy{{Name: "policy-name"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing policies",
+			fields: fields{
+				BaseURL: "https://example.com",
+				APIKey:  "api-key",
 			},
 			wantErr: true,
 		},
 		{
 			name: "missing policy name",
 			fields: fields{
-				BaseURL:    "https://example.com",
-				APIKey:     "api-key",
-				PolicyName: "",
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: ""}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate policy name",
+			fields: fields{
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: "policy-name"}, {Name: "policy-name"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid max policy condition removals",
+			fields: fields{
+				BaseURL:                    "https://example.com",
+				APIKey:                     "api-key",
+				Policies:                   []Policy{{Name: "policy-name"}},
+				MaxPolicyConditionRemovals: "10%",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid max policy condition removals",
+			fields: fields{
+				BaseURL:                    "https://example.com",
+				APIKey:                     "api-key",
+				Policies:                   []Policy{{Name: "policy-name"}},
+				MaxPolicyConditionRemovals: "ten",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative concurrency",
+			fields: fields{
+				BaseURL:     "https://example.com",
+				APIKey:      "api-key",
+				Policies:    []Policy{{Name: "policy-name"}},
+				Concurrency: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid project selector",
+			fields: fields{
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: "policy-name", Projects: []string{"regex:["}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "managed with state file",
+			fields: fields{
+				BaseURL:   "https://example.com",
+				APIKey:    "api-key",
+				Policies:  []Policy{{Name: "policy-name"}},
+				StateFile: "state.json",
+				Managed:   true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "managed without state file",
+			fields: fields{
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: "policy-name"}},
+				Managed:  true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "skip unchanged without state file",
+			fields: fields{
+				BaseURL:       "https://example.com",
+				APIKey:        "api-key",
+				Policies:      []Policy{{Name: "policy-name"}},
+				SkipUnchanged: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid retry max attempts",
+			fields: fields{
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: "policy-name"}},
+				Retry:    &dependencytrack.RetryOptions{MaxAttempts: 0},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative retry interval",
+			fields: fields{
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: "policy-name"}},
+				Retry:    &dependencytrack.RetryOptions{MaxAttempts: 5, InitialInterval: -time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "audit with analysis state",
+			fields: fields{
+				BaseURL:            "https://example.com",
+				APIKey:             "api-key",
+				Policies:           []Policy{{Name: "policy-name"}},
+				Audit:              true,
+				AuditAnalysisState: "EXPLOITABLE",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid audit analysis state",
+			fields: fields{
+				BaseURL:            "https://example.com",
+				APIKey:             "api-key",
+				Policies:           []Policy{{Name: "policy-name"}},
+				Audit:              true,
+				AuditAnalysisState: "NOT_AFFECTED",
+			},
+			wantErr: true,
+		},
+		{
+			name: "audit analysis state without audit",
+			fields: fields{
+				BaseURL:            "https://example.com",
+				APIKey:             "api-key",
+				Policies:           []Policy{{Name: "policy-name"}},
+				AuditAnalysisState: "IN_TRIAGE",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid filter",
+			fields: fields{
+				BaseURL:  "https://example.com",
+				APIKey:   "api-key",
+				Policies: []Policy{{Name: "policy-name", Filter: Filter{DateAddedSince: "invalid"}}},
 			},
 			wantErr: true,
 		},
@@ -48,13 +214,21 @@ func TestConfig_Validate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Config{
-				BaseURL:              tt.fields.BaseURL,
-				APIKey:               tt.fields.APIKey,
-				PolicyName:           tt.fields.PolicyName,
-				PolicyOperator:       tt.fields.PolicyOperator,
-				PolicyViolationState: tt.fields.PolicyViolationState,
-				PolicyProjects:       tt.fields.PolicyProjects,
-				PolicyTags:           tt.fields.PolicyTags,
+				BaseURL:  tt.fields.BaseURL,
+				APIKey:   tt.fields.APIKey,
+				Policies: tt.fields.Policies,
+
+				MaxPolicyConditionRemovals: tt.fields.MaxPolicyConditionRemovals,
+				Concurrency:                tt.fields.Concurrency,
+				StateFile:                  tt.fields.StateFile,
+				Managed:                    tt.fields.Managed,
+				SkipUnchanged:              tt.fields.SkipUnchanged,
+				Audit:                      tt.fields.Audit,
+				AuditAnalysisState:         tt.fields.AuditAnalysisState,
+				Retry:                      dependencytrack.RetryOptions{MaxAttempts: 1},
+			}
+			if tt.fields.Retry != nil {
+				c.Retry = *tt.fields.Retry
 			}
 			if err := c.Validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
@@ -62,3 +236,43 @@ func TestConfig_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestNew(t *testing.T) {
+	c := New("https://example.com", "api-key", []Policy{
+		{Name: "default"},
+		{Name: "custom", Operator: "ALL", ViolationState: "FAIL"},
+	}, false)
+
+	if c.Policies[0].Operator != DefaultPolicyOperator || c.Policies[0].ViolationState != DefaultPolicyViolationState {
+		t.Errorf("New() did not set defaults: %+v", c.Policies[0])
+	}
+	if c.Policies[1].Operator != "ALL" || c.Policies[1].ViolationState != "FAIL" {
+		t.Errorf("New() overwrote policy settings: %+v", c.Policies[1])
+	}
+	if c.Concurrency != DefaultConcurrency {
+		t.Errorf("New() Concurrency = %d, want %d", c.Concurrency, DefaultConcurrency)
+	}
+}
+
+func TestConfig_Hash(t *testing.T) {
+	newConfig := func(violationState string, dryRun bool) *Config {
+		return New("https://example.com", "api-key", []Policy{
+			{Name: "kev", ViolationState: violationState, Filter: Filter{DateAddedSince: "90d"}},
+		}, dryRun)
+	}
+
+	hash := func(c *Config) string {
+		h, err := c.Hash()
+		if err != nil {
+			t.Fatalf("Config.Hash() error = %v", err)
+		}
+		return h
+	}
+
+	if hash(newConfig("FAIL", false)) != hash(newConfig("FAIL", true)) {
+		t.Errorf("Config.Hash() differs by dry-run")
+	}
+	if hash(newConfig("FAIL", false)) == hash(newConfig("WARN", false)) {
+		t.Errorf("Config.Hash() does not differ by violation state")
+	}
+}
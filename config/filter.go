@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/takumakume/kev-to-dependencytrack/kev"
+)
+
+// Filter selects the KEV entries which become policy conditions of a policy.
+// Dates are either absolute ("2006-01-02") or relative to now ("90d" is 90 days ago).
+type Filter struct {
+	VendorProjects []string `mapstructure:"vendor-projects"`
+	Products       []string `mapstructure:"products"`
+	DateAddedSince string   `mapstructure:"date-added-since"`
+	DateAddedUntil string   `mapstructure:"date-added-until"`
+	DueDateSince   string   `mapstructure:"due-date-since"`
+	DueDateUntil   string   `mapstructure:"due-date-until"`
+	CveIDRegex     string   `mapstructure:"cve-id-regex"`
+
+	KnownRansomwareCampaignUse string   `mapstructure:"known-ransomware-campaign-use"`
+	CWEs                       []string `mapstructure:"cwes"`
+}
+
+// DecodeHook is the decode hook of the policies in the config file. YAML reads
+// an unquoted date such as 2023-01-01 as a timestamp, which is turned back into
+// the date string expected by Filter.
+func DecodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		timeToDateHookFunc,
+		// the default hooks of viper
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)
+}
+
+func timeToDateHookFunc(from, to reflect.Type, data interface{}) (interface{}, error) {
+	t, ok := data.(time.Time)
+	if !ok || to.Kind() != reflect.String {
+		return data, nil
+	}
+	return t.Format(kev.DATE_FORMAT), nil
+}
+
+// KEVFilter parses f into a kev.Filter. Relative dates are resolved against now.
+func (f Filter) KEVFilter(now time.Time) (kf kev.Filter, err error) {
+	kf.VendorProjects = f.VendorProjects
+	kf.Products = f.Products
+	kf.CWEs = f.CWEs
+
+	switch {
+	case f.KnownRansomwareCampaignUse == "",
+		strings.EqualFold(f.KnownRansomwareCampaignUse, kev.KNOWN_RANSOMWARE_CAMPAIGN_USE_KNOWN),
+		strings.EqualFold(f.KnownRansomwareCampaignUse, kev.KNOWN_RANSOMWARE_CAMPAIGN_USE_UNKNOWN):
+		kf.KnownRansomwareCampaignUse = f.KnownRansomwareCampaignUse
+	default:
+		return kf, fmt.Errorf("known-ransomware-campaign-use: invalid value %q: expected %s or %s", f.KnownRansomwareCampaignUse, kev.KNOWN_RANSOMWARE_CAMPAIGN_USE_KNOWN, kev.KNOWN_RANSOMWARE_CAMPAIGN_USE_UNKNOWN)
+	}
+
+	if kf.DateAddedSince, err = parseDate(f.DateAddedSince, now); err != nil {
+		return kf, fmt.Errorf("date-added-since: %w", err)
+	}
+	if kf.DateAddedUntil, err = parseDate(f.DateAddedUntil, now); err != nil {
+		return kf, fmt.Errorf("date-added-until: %w", err)
+	}
+	if kf.DueDateSince, err = parseDate(f.DueDateSince, now); err != nil {
+		return kf, fmt.Errorf("due-date-since: %w", err)
+	}
+	if kf.DueDateUntil, err = parseDate(f.DueDateUntil, now); err != nil {
+		return kf, fmt.Errorf("due-date-until: %w", err)
+	}
+
+	if f.CveIDRegex != "" {
+		if kf.CveID, err = regexp.Compile(f.CveIDRegex); err != nil {
+			return kf, fmt.Errorf("cve-id-regex: %w", err)
+		}
+	}
+
+	return kf, nil
+}
+
+func parseDate(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative date %q: expected format is <days>d", s)
+		}
+		y, m, d := now.AddDate(0, 0, -n).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	t, err := time.Parse(kev.DATE_FORMAT, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected format is %s or <days>d", s, kev.DATE_FORMAT)
+	}
+	return t, nil
+}
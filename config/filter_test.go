@@ -0,0 +1,83 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilter_KEVFilter(t *testing.T) {
+	now := time.Date(2023, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		filter             Filter
+		wantDateAddedSince time.Time
+		wantDueDateUntil   time.Time
+		wantErr            bool
+	}{
+		{
+			name:   "empty filter",
+			filter: Filter{},
+		},
+		{
+			name: "absolute dates",
+			filter: Filter{
+				DateAddedSince: "2023-01-01",
+				DueDateUntil:   "2023-12-31",
+			},
+			wantDateAddedSince: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantDueDateUntil:   time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "relative dates",
+			filter: Filter{
+				DateAddedSince: "90d",
+				DueDateUntil:   "0d",
+			},
+			wantDateAddedSince: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantDueDateUntil:   time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "invalid date",
+			filter:  Filter{DateAddedUntil: "2023/01/01"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid relative date",
+			filter:  Filter{DueDateSince: "xd"},
+			wantErr: true,
+		},
+		{
+			name:   "knownRansomwareCampaignUse",
+			filter: Filter{KnownRansomwareCampaignUse: "known"},
+		},
+		{
+			name:    "invalid knownRansomwareCampaignUse",
+			filter:  Filter{KnownRansomwareCampaignUse: "yes"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid regex",
+			filter:  Filter{CveIDRegex: "CVE-("},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.KEVFilter(now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Filter.KEVFilter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.DateAddedSince.Equal(tt.wantDateAddedSince) {
+				t.Errorf("Filter.KEVFilter() DateAddedSince = %v, want %v", got.DateAddedSince, tt.wantDateAddedSince)
+			}
+			if !got.DueDateUntil.Equal(tt.wantDueDateUntil) {
+				t.Errorf("Filter.KEVFilter() DueDateUntil = %v, want %v", got.DueDateUntil, tt.wantDueDateUntil)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Threshold is an absolute count ("10") or a percentage of a total ("10%").
+// The zero value has no limit.
+type Threshold struct {
+	value   float64
+	percent bool
+	set     bool
+}
+
+func ParseThreshold(s string) (Threshold, error) {
+	if s == "" {
+		return Threshold{}, nil
+	}
+
+	v, percent := strings.CutSuffix(s, "%")
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: expected format is <count> or <percent>%%", s)
+	}
+
+	return Threshold{value: f, percent: percent, set: true}, nil
+}
+
+// Exceeded reports whether n of total is above the threshold.
+func (t Threshold) Exceeded(n, total int) bool {
+	if !t.set {
+		return false
+	}
+
+	if t.percent {
+		if total == 0 {
+			return false
+		}
+		return float64(n)*100/float64(total) > t.value
+	}
+
+	return float64(n) > t.value
+}
+
+func (t Threshold) String() string {
+	if !t.set {
+		return ""
+	}
+
+	s := strconv.FormatFloat(t.value, 'f', -1, 64)
+	if t.percent {
+		return s + "%"
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package config
+
+import "testing"
+
+func TestThreshold_Exceeded(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold string
+		n         int
+		total     int
+		want      bool
+		wantErr   bool
+	}{
+		{
+			name:      "no limit",
+			threshold: "",
+			n:         100,
+			total:     100,
+			want:      false,
+		},
+		{
+			name:      "count not exceeded",
+			threshold: "10",
+			n:         10,
+			total:     100,
+			want:      false,
+		},
+		{
+			name:      "count exceeded",
+			threshold: "10",
+			n:         11,
+			total:     100,
+			want:      true,
+		},
+		{
+			name:      "percent not exceeded",
+			threshold: "50%",
+			n:         50,
+			total:     100,
+			want:      false,
+		},
+		{
+			name:      "percent exceeded",
+			threshold: "50%",
+			n:         51,
+			total:     100,
+			want:      true,
+		},
+		{
+			name:      "percent with empty total",
+			threshold: "0%",
+			n:         0,
+			total:     0,
+			want:      false,
+		},
+		{
+			name:      "invalid",
+			threshold: "ten",
+			wantErr:   true,
+		},
+		{
+			name:      "negative",
+			threshold: "-1%",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th, err := ParseThreshold(tt.threshold)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseThreshold() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := th.String(); got != tt.threshold {
+				t.Errorf("Threshold.String() = %s, want %s", got, tt.threshold)
+			}
+			if got := th.Exceeded(tt.n, tt.total); got != tt.want {
+				t.Errorf("Threshold.Exceeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package dependencytrack
 import (
 	"context"
 	"errors"
-	"time"
+	"log"
+	"net/http"
+	"sync"
 
 	dtrack "github.com/DependencyTrack/client-go"
 	"github.com/google/uuid"
@@ -18,18 +20,27 @@ type DependencyTrackClient interface {
 	DeleteTag(ctx context.Context, policyUUID uuid.UUID, tagName string) (p dtrack.Policy, err error)
 	AddProject(ctx context.Context, policyUUID, projectUUID uuid.UUID) (p dtrack.Policy, err error)
 	DeleteProject(ctx context.Context, policyUUID, projectUUID uuid.UUID) (p dtrack.Policy, err error)
+	GetProjects(ctx context.Context) (pp []dtrack.Project, err error)
 	GetProjectsForName(ctx context.Context, projectName string, excludeInactive, onlyRoot bool) (pp []dtrack.Project, err error)
 	GetProjectForNameVersion(ctx context.Context, projectName, projectVersion string, excludeInactive, onlyRoot bool) (p dtrack.Project, err error)
 	CreatePolicyCondition(ctx context.Context, policyUUID uuid.UUID, policyCondition dtrack.PolicyCondition) (p dtrack.PolicyCondition, err error)
 	DeletePolicyCondition(ctx context.Context, policyConditionUUID uuid.UUID) (err error)
+	GetFindings(ctx context.Context, projectUUID uuid.UUID) (ff []dtrack.Finding, err error)
+	GetAnalysis(ctx context.Context, componentUUID, projectUUID, vulnerabilityUUID uuid.UUID) (a dtrack.Analysis, err error)
+	RecordAnalysis(ctx context.Context, analysis dtrack.AnalysisRequest) (a dtrack.Analysis, err error)
 }
 
 type DependencyTrack struct {
 	Client *dtrack.Client
+
+	quirksOnce sync.Once
+	quirks     Quirks
 }
 
-func New(baseURL, apiKey string, timeout time.Duration) (*DependencyTrack, error) {
-	client, err := dtrack.NewClient(baseURL, dtrack.WithAPIKey(apiKey), dtrack.WithTimeout(timeout), dtrack.WithDebug(false))
+// New returns a Dependency Track client sending the requests with httpClient,
+// which carries the timeout, TLS and proxy settings.
+func New(baseURL, apiKey string, httpClient *http.Client) (*DependencyTrack, error) {
+	client, err := dtrack.NewClient(baseURL, dtrack.WithAPIKey(apiKey), dtrack.WithHttpClient(httpClient), dtrack.WithDebug(false))
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +71,13 @@ func IsNotFound(err error) bool {
 	return false
 }
 
+// IsNotModified reports whether the server answered 304, which it does for
+// example when a tag or a project is already assigned to the policy.
+func IsNotModified(err error) bool {
+	var apiErr *dtrack.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == 304
+}
+
 func (d *DependencyTrack) GetPolicyForName(ctx context.Context, policyName string) (p dtrack.Policy, err error) {
 	policies, err := dtrack.FetchAll(func(po dtrack.PageOptions) (dtrack.Page[dtrack.Policy], error) {
 		return d.Client.Policy.GetAll(ctx, po)
@@ -76,18 +94,41 @@ func (d *DependencyTrack) GetPolicyForName(ctx context.Context, policyName strin
 	return p, ErrPolicyNotFound
 }
 
+// Quirks returns the quirks of the Dependency Track server, detected from its
+// version on the first call. When the version is unknown, every quirk is assumed.
+func (d *DependencyTrack) Quirks(ctx context.Context) Quirks {
+	d.quirksOnce.Do(func() {
+		about, err := d.Client.About.Get(ctx)
+		if err != nil {
+			log.Printf("WARN: detect Dependency Track version: %v", err)
+			d.quirks = QuirksFor(nil)
+			return
+		}
+
+		v, err := ParseVersion(about.Version)
+		if err != nil {
+			log.Printf("WARN: detect Dependency Track version: %v", err)
+			d.quirks = QuirksFor(nil)
+			return
+		}
+
+		log.Printf("Dependency Track version %s", v)
+		d.quirks = QuirksFor(&v)
+	})
+
+	return d.quirks
+}
+
 func (d *DependencyTrack) CreatePolicy(ctx context.Context, policy dtrack.Policy) (p dtrack.Policy, err error) {
-	po, err := d.Client.Policy.Create(ctx, policy)
+	p, err = d.Client.Policy.Create(ctx, policy)
 	if err != nil {
 		return p, err
 	}
 
-	// FIXME: https://github.com/DependencyTrack/dependency-track/issues/2365
-	po.ViolationState = policy.ViolationState
-	po.Operator = policy.Operator
-	p, err = d.UpdatePolicy(ctx, po)
-	if err != nil {
-		return p, err
+	if d.Quirks(ctx).Has(QuirkCreatePolicyIgnoresOperatorAndViolationState) {
+		p.ViolationState = policy.ViolationState
+		p.Operator = policy.Operator
+		return d.UpdatePolicy(ctx, p)
 	}
 
 	return p, nil
@@ -123,6 +164,13 @@ func (d *DependencyTrack) DeleteProject(ctx context.Context, policyUUID, project
 	return d.Client.Policy.DeleteProject(ctx, policyUUID, projectUUID)
 }
 
+// GetProjects returns every project including the inactive and the child ones.
+func (d *DependencyTrack) GetProjects(ctx context.Context) (pp []dtrack.Project, err error) {
+	return dtrack.FetchAll(func(po dtrack.PageOptions) (dtrack.Page[dtrack.Project], error) {
+		return d.Client.Project.GetAll(ctx, po)
+	})
+}
+
 func (d *DependencyTrack) GetProjectsForName(ctx context.Context, projectName string, excludeInactive, onlyRoot bool) (pp []dtrack.Project, err error) {
 	pp, err = d.Client.Project.GetProjectsForName(ctx, projectName, excludeInactive, onlyRoot)
 	if err != nil {
@@ -154,3 +202,19 @@ func (d *DependencyTrack) CreatePolicyCondition(ctx context.Context, policyUUID
 func (d *DependencyTrack) DeletePolicyCondition(ctx context.Context, policyConditionUUID uuid.UUID) (err error) {
 	return d.Client.PolicyCondition.Delete(ctx, policyConditionUUID)
 }
+
+// GetFindings returns the findings of the project, excluding the suppressed ones.
+func (d *DependencyTrack) GetFindings(ctx context.Context, projectUUID uuid.UUID) (ff []dtrack.Finding, err error) {
+	return dtrack.FetchAll(func(po dtrack.PageOptions) (dtrack.Page[dtrack.Finding], error) {
+		return d.Client.Finding.GetAll(ctx, projectUUID, false, po)
+	})
+}
+
+func (d *DependencyTrack) GetAnalysis(ctx context.Context, componentUUID, projectUUID, vulnerabilityUUID uuid.UUID) (a dtrack.Analysis, err error) {
+	return d.Client.Analysis.Get(ctx, componentUUID, projectUUID, vulnerabilityUUID)
+}
+
+// RecordAnalysis creates or updates the analysis of a finding.
+func (d *DependencyTrack) RecordAnalysis(ctx context.Context, analysis dtrack.AnalysisRequest) (a dtrack.Analysis, err error) {
+	return d.Client.Analysis.Create(ctx, analysis)
+}
@@ -1,7 +1,11 @@
 package dependencytrack
 
 import (
+	"context"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	dtrack "github.com/DependencyTrack/client-go"
@@ -109,3 +113,71 @@ func TestDependencyTrack_NeedsUpdatePolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestDependencyTrack_CreatePolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		wantUpdates int
+	}{
+		{
+			name:        "affected version",
+			version:     `{"version":"4.7.1"}`,
+			wantUpdates: 1,
+		},
+		{
+			name:        "fixed version",
+			version:     `{"version":"4.8.0"}`,
+			wantUpdates: 0,
+		},
+		{
+			name:        "unknown version",
+			version:     `{}`,
+			wantUpdates: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versionRequests, updates := 0, 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.URL.Path == "/api/version":
+					versionRequests++
+					w.Write([]byte(tt.version))
+				case r.URL.Path == "/api/v1/policy" && r.Method == http.MethodPut:
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`{"name":"kev","operator":"ANY","violationState":"INFO"}`))
+				case r.URL.Path == "/api/v1/policy" && r.Method == http.MethodPost:
+					updates++
+					io.Copy(w, r.Body)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer ts.Close()
+
+			d, err := New(ts.URL, "api-key", ts.Client())
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			for i := 0; i < 2; i++ {
+				p, err := d.CreatePolicy(context.Background(), dtrack.Policy{Name: "kev", Operator: dtrack.PolicyOperatorAll, ViolationState: dtrack.PolicyViolationStateFail})
+				if err != nil {
+					t.Fatalf("DependencyTrack.CreatePolicy() error = %v", err)
+				}
+				if tt.wantUpdates > 0 && (p.Operator != dtrack.PolicyOperatorAll || p.ViolationState != dtrack.PolicyViolationStateFail) {
+					t.Errorf("DependencyTrack.CreatePolicy() = %+v, want operator and violationState applied", p)
+				}
+			}
+
+			if versionRequests != 1 {
+				t.Errorf("version requested %d times, want 1", versionRequests)
+			}
+			if updates != 2*tt.wantUpdates {
+				t.Errorf("policy updated %d times, want %d", updates, 2*tt.wantUpdates)
+			}
+		})
+	}
+}
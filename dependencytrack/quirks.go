@@ -0,0 +1,86 @@
+package dependencytrack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Quirk is a bug of some Dependency Track versions worked around by the client.
+type Quirk string
+
+const (
+	// QuirkCreatePolicyIgnoresOperatorAndViolationState: creating a policy ignores
+	// its operator and violationState, so the created policy is updated with them.
+	// https://github.com/DependencyTrack/dependency-track/issues/2365
+	QuirkCreatePolicyIgnoresOperatorAndViolationState Quirk = "create-policy-ignores-operator-and-violation-state"
+)
+
+// knownQuirks lists every quirk with the first Dependency Track version without it.
+var knownQuirks = []struct {
+	quirk   Quirk
+	fixedIn Version
+}{
+	{quirk: QuirkCreatePolicyIgnoresOperatorAndViolationState, fixedIn: Version{Major: 4, Minor: 8, Patch: 0}},
+}
+
+// Quirks is the set of quirks of a Dependency Track server.
+type Quirks map[Quirk]bool
+
+// QuirksFor returns the quirks of the version. A nil version is unknown and has every quirk,
+// as the workarounds are harmless on the fixed versions.
+func QuirksFor(v *Version) Quirks {
+	q := Quirks{}
+	for _, k := range knownQuirks {
+		if v == nil || v.Less(k.fixedIn) {
+			q[k.quirk] = true
+		}
+	}
+	return q
+}
+
+func (q Quirks) Has(quirk Quirk) bool {
+	return q[quirk]
+}
+
+// Version is a Dependency Track version such as 4.8.2.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses the version returned by /api/version, ignoring a
+// pre-release suffix such as "-SNAPSHOT".
+func ParseVersion(s string) (Version, error) {
+	core, _, _ := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	parts := strings.Split(core, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version: %q", s)
+	}
+
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version: %q", s)
+		}
+		nums[i] = n
+	}
+
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
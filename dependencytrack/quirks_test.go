@@ -0,0 +1,53 @@
+package dependencytrack
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Version
+		wantErr bool
+	}{
+		{s: "4.7.1", want: Version{Major: 4, Minor: 7, Patch: 1}},
+		{s: "4.8", want: Version{Major: 4, Minor: 8}},
+		{s: "4.10.0-SNAPSHOT", want: Version{Major: 4, Minor: 10}},
+		{s: "v5.0.0", want: Version{Major: 5}},
+		{s: "", wantErr: true},
+		{s: "4", wantErr: true},
+		{s: "4.x.0", wantErr: true},
+		{s: "4.8.0.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseVersion(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuirksFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		version *Version
+		want    bool
+	}{
+		{name: "unknown", version: nil, want: true},
+		{name: "4.7.1", version: &Version{Major: 4, Minor: 7, Patch: 1}, want: true},
+		{name: "4.8.0", version: &Version{Major: 4, Minor: 8, Patch: 0}, want: false},
+		{name: "4.10.0", version: &Version{Major: 4, Minor: 10, Patch: 0}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuirksFor(tt.version).Has(QuirkCreatePolicyIgnoresOperatorAndViolationState); got != tt.want {
+				t.Errorf("QuirksFor().Has() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package dependencytrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/google/uuid"
+)
+
+type RetryOptions struct {
+	// MaxAttempts is the number of attempts including the first one. 1 disables retries.
+	MaxAttempts int
+	// InitialInterval is the wait before the first retry, doubled on each retry up to MaxInterval.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// MaxElapsedTime is the budget of a call including the retries. 0 is unlimited.
+	MaxElapsedTime time.Duration
+}
+
+func (o RetryOptions) Validate() error {
+	if o.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1: %d", o.MaxAttempts)
+	}
+	if o.InitialInterval < 0 {
+		return fmt.Errorf("initial interval must not be negative: %s", o.InitialInterval)
+	}
+	if o.MaxInterval < 0 {
+		return fmt.Errorf("max interval must not be negative: %s", o.MaxInterval)
+	}
+	if o.MaxElapsedTime < 0 {
+		return fmt.Errorf("max elapsed time must not be negative: %s", o.MaxElapsedTime)
+	}
+	return nil
+}
+
+// retryClient retries the calls of a DependencyTrackClient failing with a
+// transient error with jittered exponential backoff.
+type retryClient struct {
+	client DependencyTrackClient
+	opts   RetryOptions
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+func WithRetry(client DependencyTrackClient, opts RetryOptions) DependencyTrackClient {
+	return &retryClient{
+		client: client,
+		opts:   opts,
+		sleep:  sleep,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// IsTransient reports whether err is worth retrying: a 5xx or 429 response,
+// a timeout or a broken connection.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *dtrack.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == 429
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, io.EOF):
+		return true
+	}
+
+	return false
+}
+
+// IsUnprocessed reports whether err is transient and the request certainly
+// was not processed by the server: the connection was refused, or the server
+// answered 429 or 503. Unlike IsTransient, it is safe for requests which are
+// not idempotent, such as creates.
+func IsUnprocessed(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *dtrack.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == 429 || apiErr.StatusCode == 503
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// do calls f until it succeeds or fails with an error which is not transient.
+func (c *retryClient) do(ctx context.Context, method string, f func() error) error {
+	return c.doIf(ctx, method, IsTransient, f)
+}
+
+// doApplied is do for a request which an attempt failing with a transient
+// error may have applied anyway. From the second attempt on, an error for
+// which applied reports true means so, and is returned as success.
+func (c *retryClient) doApplied(ctx context.Context, method string, applied func(error) bool, f func() error) error {
+	attempt := 0
+	return c.do(ctx, method, func() error {
+		attempt++
+		err := f()
+		if err != nil && attempt > 1 && applied(err) {
+			log.Printf("WARN: %s: applied by an earlier attempt: %v", method, err)
+			return nil
+		}
+		return err
+	})
+}
+
+func isDeleted(err error) bool {
+	return IsNotFound(err) || IsNotModified(err)
+}
+
+// doIf calls f until it succeeds or fails with an error which is not retryable.
+func (c *retryClient) doIf(ctx context.Context, method string, retryable func(error) bool, f func() error) error {
+	start := time.Now()
+	interval := c.opts.InitialInterval
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || !retryable(err) || attempt >= c.opts.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		// equal jitter: half of the interval is fixed, the other half is random
+		wait := interval
+		if interval > 0 {
+			wait = interval/2 + time.Duration(rand.Int63n(int64(interval/2)+1))
+		}
+		if c.opts.MaxElapsedTime > 0 && time.Since(start)+wait > c.opts.MaxElapsedTime {
+			return err
+		}
+
+		log.Printf("WARN: %s: retrying in %s (attempt %d/%d): %v", method, wait, attempt, c.opts.MaxAttempts, err)
+		if serr := c.sleep(ctx, wait); serr != nil {
+			return err
+		}
+
+		interval *= 2
+		if c.opts.MaxInterval > 0 && interval > c.opts.MaxInterval {
+			interval = c.opts.MaxInterval
+		}
+	}
+}
+
+func (c *retryClient) GetPolicyForName(ctx context.Context, policyName string) (p dtrack.Policy, err error) {
+	err = c.do(ctx, "GetPolicyForName", func() error {
+		p, err = c.client.GetPolicyForName(ctx, policyName)
+		return err
+	})
+	return p, err
+}
+
+// CreatePolicy retries on transient errors, but a failed attempt may have
+// created the policy, so the policy is looked up before each retry and
+// returned, updated if needed, when it exists.
+func (c *retryClient) CreatePolicy(ctx context.Context, policy dtrack.Policy) (p dtrack.Policy, err error) {
+	attempted := false
+	err = c.do(ctx, "CreatePolicy", func() error {
+		if attempted {
+			existing, err := c.client.GetPolicyForName(ctx, policy.Name)
+			if err == nil {
+				if !c.client.NeedsUpdatePolicy(existing, policy) {
+					p = existing
+					return nil
+				}
+				existing.Operator = policy.Operator
+				existing.ViolationState = policy.ViolationState
+				p, err = c.client.UpdatePolicy(ctx, existing)
+				return err
+			}
+			if !IsNotFound(err) {
+				return err
+			}
+		}
+		attempted = true
+
+		p, err = c.client.CreatePolicy(ctx, policy)
+		return err
+	})
+	return p, err
+}
+
+func (c *retryClient) UpdatePolicy(ctx context.Context, policy dtrack.Policy) (p dtrack.Policy, err error) {
+	err = c.do(ctx, "UpdatePolicy", func() error {
+		p, err = c.client.UpdatePolicy(ctx, policy)
+		return err
+	})
+	return p, err
+}
+
+func (c *retryClient) NeedsUpdatePolicy(current, desierd dtrack.Policy) bool {
+	return c.client.NeedsUpdatePolicy(current, desierd)
+}
+
+func (c *retryClient) AddTag(ctx context.Context, policyUUID uuid.UUID, tagName string) (p dtrack.Policy, err error) {
+	err = c.doApplied(ctx, "AddTag", IsNotModified, func() error {
+		p, err = c.client.AddTag(ctx, policyUUID, tagName)
+		return err
+	})
+	return p, err
+}
+
+func (c *retryClient) DeleteTag(ctx context.Context, policyUUID uuid.UUID, tagName string) (p dtrack.Policy, err error) {
+	err = c.doApplied(ctx, "DeleteTag", isDeleted, func() error {
+		p, err = c.client.DeleteTag(ctx, policyUUID, tagName)
+		return err
+	})
+	return p, err
+}
+
+func (c *retryClient) AddProject(ctx context.Context, policyUUID, projectUUID uuid.UUID) (p dtrack.Policy, err error) {
+	err = c.doApplied(ctx, "AddProject", IsNotModified, func() error {
+		p, err = c.client.AddProject(ctx, policyUUID, projectUUID)
+		return err
+	})
+	return p, err
+}
+
+func (c *retryClient) DeleteProject(ctx context.Context, policyUUID, projectUUID uuid.UUID) (p dtrack.Policy, err error) {
+	err = c.doApplied(ctx, "DeleteProject", isDeleted, func() error {
+		p, err = c.client.DeleteProject(ctx, policyUUID, projectUUID)
+		return err
+	})
+	return p, err
+}
+
+func (c *retryClient) GetProjects(ctx context.Context) (pp []dtrack.Project, err error) {
+	err = c.do(ctx, "GetProjects", func() error {
+		pp, err = c.client.GetProjects(ctx)
+		return err
+	})
+	return pp, err
+}
+
+func (c *retryClient) GetProjectsForName(ctx context.Context, projectName string, excludeInactive, onlyRoot bool) (pp []dtrack.Project, err error) {
+	err = c.do(ctx, "GetProjectsForName", func() error {
+		pp, err = c.client.GetProjectsForName(ctx, projectName, excludeInactive, onlyRoot)
+		return err
+	})
+	return pp, err
+}
+
+func (c *retryClient) GetProjectForNameVersion(ctx context.Context, projectName, projectVersion string, excludeInactive, onlyRoot bool) (p dtrack.Project, err error) {
+	err = c.do(ctx, "GetProjectForNameVersion", func() error {
+		p, err = c.client.GetProjectForNameVersion(ctx, projectName, projectVersion, excludeInactive, onlyRoot)
+		return err
+	})
+	return p, err
+}
+
+// CreatePolicyCondition is retried only when the request was not processed,
+// as a retry could duplicate the policy condition.
+func (c *retryClient) CreatePolicyCondition(ctx context.Context, policyUUID uuid.UUID, policyCondition dtrack.PolicyCondition) (p dtrack.PolicyCondition, err error) {
+	err = c.doIf(ctx, "CreatePolicyCondition", IsUnprocessed, func() error {
+		p, err = c.client.CreatePolicyCondition(ctx, policyUUID, policyCondition)
+		return err
+	})
+	return p, err
+}
+
+func (c *retryClient) DeletePolicyCondition(ctx context.Context, policyConditionUUID uuid.UUID) (err error) {
+	return c.doApplied(ctx, "DeletePolicyCondition", isDeleted, func() error {
+		return c.client.DeletePolicyCondition(ctx, policyConditionUUID)
+	})
+}
+
+func (c *retryClient) GetFindings(ctx context.Context, projectUUID uuid.UUID) (ff []dtrack.Finding, err error) {
+	err = c.do(ctx, "GetFindings", func() error {
+		ff, err = c.client.GetFindings(ctx, projectUUID)
+		return err
+	})
+	return ff, err
+}
+
+func (c *retryClient) GetAnalysis(ctx context.Context, componentUUID, projectUUID, vulnerabilityUUID uuid.UUID) (a dtrack.Analysis, err error) {
+	err = c.do(ctx, "GetAnalysis", func() error {
+		a, err = c.client.GetAnalysis(ctx, componentUUID, projectUUID, vulnerabilityUUID)
+		return err
+	})
+	return a, err
+}
+
+// RecordAnalysis is retried only when the request was not processed,
+// as a retry could duplicate the comment.
+func (c *retryClient) RecordAnalysis(ctx context.Context, analysis dtrack.AnalysisRequest) (a dtrack.Analysis, err error) {
+	err = c.doIf(ctx, "RecordAnalysis", IsUnprocessed, func() error {
+		a, err = c.client.RecordAnalysis(ctx, analysis)
+		return err
+	})
+	return a, err
+}
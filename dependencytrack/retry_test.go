@@ -0,0 +1,427 @@
+package dependencytrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/google/uuid"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "nil",
+			err:  nil,
+			want: false,
+		},
+		{
+			name: "api error 502",
+			err:  &dtrack.APIError{StatusCode: 502},
+			want: true,
+		},
+		{
+			name: "api error 429",
+			err:  &dtrack.APIError{StatusCode: 429},
+			want: true,
+		},
+		{
+			name: "api error 404",
+			err:  &dtrack.APIError{StatusCode: 404},
+			want: false,
+		},
+		{
+			name: "wrapped api error 503",
+			err:  fmt.Errorf("wrapped: %w", &dtrack.APIError{StatusCode: 503}),
+			want: true,
+		},
+		{
+			name: "timeout",
+			err:  &url.Error{Op: "Get", URL: "http://127.0.0.1", Err: timeoutError{}},
+			want: true,
+		},
+		{
+			name: "connection reset",
+			err:  &url.Error{Op: "Get", URL: "http://127.0.0.1", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}},
+			want: true,
+		},
+		{
+			name: "unexpected EOF",
+			err:  &url.Error{Op: "Get", URL: "http://127.0.0.1", Err: io.ErrUnexpectedEOF},
+			want: true,
+		},
+		{
+			name: "canceled",
+			err:  &url.Error{Op: "Get", URL: "http://127.0.0.1", Err: context.Canceled},
+			want: false,
+		},
+		{
+			name: "policy not found",
+			err:  ErrPolicyNotFound,
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_retryClient_do(t *testing.T) {
+	transient := &dtrack.APIError{StatusCode: 502}
+	permanent := &dtrack.APIError{StatusCode: 400}
+
+	tests := []struct {
+		name         string
+		opts         RetryOptions
+		errs         []error
+		wantErr      error
+		wantAttempts int
+		wantWaits    []time.Duration
+	}{
+		{
+			name:         "success",
+			opts:         RetryOptions{MaxAttempts: 3, InitialInterval: time.Second},
+			errs:         []error{nil},
+			wantErr:      nil,
+			wantAttempts: 1,
+		},
+		{
+			name:         "success after transient errors",
+			opts:         RetryOptions{MaxAttempts: 3, InitialInterval: time.Second, MaxInterval: 10 * time.Second},
+			errs:         []error{transient, transient, nil},
+			wantErr:      nil,
+			wantAttempts: 3,
+			wantWaits:    []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:         "max attempts",
+			opts:         RetryOptions{MaxAttempts: 2, InitialInterval: time.Second},
+			errs:         []error{transient, transient, nil},
+			wantErr:      transient,
+			wantAttempts: 2,
+			wantWaits:    []time.Duration{time.Second},
+		},
+		{
+			name:         "max interval",
+			opts:         RetryOptions{MaxAttempts: 4, InitialInterval: time.Second, MaxInterval: time.Second},
+			errs:         []error{transient, transient, transient, nil},
+			wantErr:      nil,
+			wantAttempts: 4,
+			wantWaits:    []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:         "permanent error",
+			opts:         RetryOptions{MaxAttempts: 3, InitialInterval: time.Second},
+			errs:         []error{permanent, nil},
+			wantErr:      permanent,
+			wantAttempts: 1,
+		},
+		{
+			name:         "max elapsed time",
+			opts:         RetryOptions{MaxAttempts: 3, InitialInterval: time.Minute, MaxElapsedTime: time.Second},
+			errs:         []error{transient, nil},
+			wantErr:      transient,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := []time.Duration{}
+			c := &retryClient{
+				opts: tt.opts,
+				sleep: func(ctx context.Context, d time.Duration) error {
+					waits = append(waits, d)
+					return nil
+				},
+			}
+
+			attempts := 0
+			err := c.do(context.Background(), "test", func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("retryClient.do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("retryClient.do() attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if len(waits) != len(tt.wantWaits) {
+				t.Fatalf("retryClient.do() waits = %v, want %v", waits, tt.wantWaits)
+			}
+			for i, w := range waits {
+				// the wait is jittered between the half and the whole interval
+				if w < tt.wantWaits[i]/2 || w > tt.wantWaits[i] {
+					t.Errorf("retryClient.do() wait[%d] = %v, want between %v and %v", i, w, tt.wantWaits[i]/2, tt.wantWaits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsUnprocessed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"429", &dtrack.APIError{StatusCode: 429}, true},
+		{"503", &dtrack.APIError{StatusCode: 503}, true},
+		{"502", &dtrack.APIError{StatusCode: 502}, false},
+		{"504", &dtrack.APIError{StatusCode: 504}, false},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{"timeout", timeoutError{}, false},
+		{"eof", io.EOF, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUnprocessed(tt.err); got != tt.want {
+				t.Errorf("IsUnprocessed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeClient implements the methods of DependencyTrackClient used by the
+// retryClient tests; the others panic.
+type fakeClient struct {
+	DependencyTrackClient
+
+	createPolicyErrs           []error
+	createPolicyCalls          int
+	policies                   map[string]dtrack.Policy
+	updatePolicyCalls          int
+	createPolicyConditionErrs  []error
+	createPolicyConditionCalls int
+	deletePolicyConditionErrs  []error
+	deletePolicyConditionCalls int
+	addTagErrs                 []error
+	addTagCalls                int
+}
+
+func (f *fakeClient) CreatePolicy(ctx context.Context, policy dtrack.Policy) (dtrack.Policy, error) {
+	err := f.createPolicyErrs[f.createPolicyCalls]
+	f.createPolicyCalls++
+	// the policy is created even when the response is lost
+	f.policies[policy.Name] = dtrack.Policy{Name: policy.Name}
+	return policy, err
+}
+
+func (f *fakeClient) GetPolicyForName(ctx context.Context, policyName string) (dtrack.Policy, error) {
+	p, ok := f.policies[policyName]
+	if !ok {
+		return p, ErrPolicyNotFound
+	}
+	return p, nil
+}
+
+func (f *fakeClient) NeedsUpdatePolicy(current, desierd dtrack.Policy) bool {
+	return current.Operator != desierd.Operator || current.ViolationState != desierd.ViolationState
+}
+
+func (f *fakeClient) UpdatePolicy(ctx context.Context, policy dtrack.Policy) (dtrack.Policy, error) {
+	f.updatePolicyCalls++
+	f.policies[policy.Name] = policy
+	return policy, nil
+}
+
+func (f *fakeClient) CreatePolicyCondition(ctx context.Context, policyUUID uuid.UUID, policyCondition dtrack.PolicyCondition) (dtrack.PolicyCondition, error) {
+	err := f.createPolicyConditionErrs[f.createPolicyConditionCalls]
+	f.createPolicyConditionCalls++
+	return policyCondition, err
+}
+
+func (f *fakeClient) DeletePolicyCondition(ctx context.Context, policyConditionUUID uuid.UUID) error {
+	err := f.deletePolicyConditionErrs[f.deletePolicyConditionCalls]
+	f.deletePolicyConditionCalls++
+	return err
+}
+
+func (f *fakeClient) AddTag(ctx context.Context, policyUUID uuid.UUID, tagName string) (dtrack.Policy, error) {
+	err := f.addTagErrs[f.addTagCalls]
+	f.addTagCalls++
+	return dtrack.Policy{}, err
+}
+
+func newTestRetryClient(client DependencyTrackClient) *retryClient {
+	return &retryClient{
+		client: client,
+		opts:   RetryOptions{MaxAttempts: 3},
+		sleep:  func(ctx context.Context, d time.Duration) error { return nil },
+	}
+}
+
+func Test_retryClient_CreatePolicy(t *testing.T) {
+	f := &fakeClient{
+		createPolicyErrs: []error{timeoutError{}, nil},
+		policies:         map[string]dtrack.Policy{},
+	}
+	c := newTestRetryClient(f)
+
+	desierd := dtrack.Policy{Name: "kev", Operator: dtrack.PolicyOperatorAny, ViolationState: dtrack.PolicyViolationStateFail}
+	p, err := c.CreatePolicy(context.Background(), desierd)
+	if err != nil {
+		t.Fatalf("retryClient.CreatePolicy() error = %v", err)
+	}
+	if f.createPolicyCalls != 1 {
+		t.Errorf("retryClient.CreatePolicy() created %d times, want 1", f.createPolicyCalls)
+	}
+	if f.updatePolicyCalls != 1 || p.Operator != desierd.Operator || p.ViolationState != desierd.ViolationState {
+		t.Errorf("retryClient.CreatePolicy() = %+v with %d updates, want the existing policy updated", p, f.updatePolicyCalls)
+	}
+}
+
+func Test_retryClient_CreatePolicyCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "not retried on ambiguous errors",
+			errs:      []error{&dtrack.APIError{StatusCode: 502}, nil},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "retried when not processed",
+			errs:      []error{&dtrack.APIError{StatusCode: 503}, nil},
+			wantCalls: 2,
+			wantErr:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeClient{createPolicyConditionErrs: tt.errs}
+			c := newTestRetryClient(f)
+
+			_, err := c.CreatePolicyCondition(context.Background(), uuid.New(), dtrack.PolicyCondition{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("retryClient.CreatePolicyCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if f.createPolicyConditionCalls != tt.wantCalls {
+				t.Errorf("retryClient.CreatePolicyCondition() calls = %d, want %d", f.createPolicyConditionCalls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_retryClient_DeletePolicyCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "deleted by the failed attempt",
+			errs:      []error{&dtrack.APIError{StatusCode: 502}, &dtrack.APIError{StatusCode: 404}},
+			wantCalls: 2,
+			wantErr:   false,
+		},
+		{
+			name:      "not found on the first attempt",
+			errs:      []error{&dtrack.APIError{StatusCode: 404}},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeClient{deletePolicyConditionErrs: tt.errs}
+			c := newTestRetryClient(f)
+
+			err := c.DeletePolicyCondition(context.Background(), uuid.New())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("retryClient.DeletePolicyCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if f.deletePolicyConditionCalls != tt.wantCalls {
+				t.Errorf("retryClient.DeletePolicyCondition() calls = %d, want %d", f.deletePolicyConditionCalls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_retryClient_AddTag(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "added by the failed attempt",
+			errs:      []error{&dtrack.APIError{StatusCode: 502}, &dtrack.APIError{StatusCode: 304}},
+			wantCalls: 2,
+			wantErr:   false,
+		},
+		{
+			name:      "not modified on the first attempt",
+			errs:      []error{&dtrack.APIError{StatusCode: 304}},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeClient{addTagErrs: tt.errs}
+			c := newTestRetryClient(f)
+
+			_, err := c.AddTag(context.Background(), uuid.New(), "tag")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("retryClient.AddTag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if f.addTagCalls != tt.wantCalls {
+				t.Errorf("retryClient.AddTag() calls = %d, want %d", f.addTagCalls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    RetryOptions
+		wantErr bool
+	}{
+		{"valid", RetryOptions{MaxAttempts: 5, InitialInterval: time.Second, MaxInterval: 30 * time.Second, MaxElapsedTime: 2 * time.Minute}, false},
+		{"no retries", RetryOptions{MaxAttempts: 1}, false},
+		{"zero max attempts", RetryOptions{MaxAttempts: 0}, true},
+		{"negative initial interval", RetryOptions{MaxAttempts: 5, InitialInterval: -time.Second}, true},
+		{"negative max interval", RetryOptions{MaxAttempts: 5, MaxInterval: -time.Second}, true},
+		{"negative max elapsed time", RetryOptions{MaxAttempts: 5, MaxElapsedTime: -time.Second}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("RetryOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
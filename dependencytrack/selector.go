@@ -0,0 +1,101 @@
+package dependencytrack
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	dtrack "github.com/DependencyTrack/client-go"
+)
+
+type ProjectSelectorKind string
+
+const (
+	ProjectSelectorKindName       ProjectSelectorKind = "name"
+	ProjectSelectorKindTag        ProjectSelectorKind = "tag"
+	ProjectSelectorKindRegex      ProjectSelectorKind = "regex"
+	ProjectSelectorKindClassifier ProjectSelectorKind = "classifier"
+)
+
+// ProjectSelector selects Dependency Track projects. It is written as
+//
+//	<name>[:<version>]       projects of the name, optionally only the version
+//	name:<name>[:<version>]  the same, for names starting with a selector kind
+//	tag:<tag>                projects having the tag
+//	regex:<regexp>           projects whose name matches the regular expression
+//	classifier:<classifier>  projects of the classifier, e.g. APPLICATION
+//
+// and prefixed with "!" to exclude the selected projects.
+type ProjectSelector struct {
+	Kind    ProjectSelectorKind
+	Value   string
+	Version string
+	Exclude bool
+
+	raw   string
+	regex *regexp.Regexp
+}
+
+// ParseProjectSelector parses s as a ProjectSelector.
+func ParseProjectSelector(s string) (ProjectSelector, error) {
+	selector := ProjectSelector{raw: s}
+
+	rest, exclude := strings.CutPrefix(s, "!")
+	selector.Exclude = exclude
+
+	selector.Kind = ProjectSelectorKindName
+	for _, kind := range []ProjectSelectorKind{ProjectSelectorKindName, ProjectSelectorKindTag, ProjectSelectorKindRegex, ProjectSelectorKindClassifier} {
+		if v, ok := strings.CutPrefix(rest, string(kind)+":"); ok {
+			selector.Kind = kind
+			rest = v
+			break
+		}
+	}
+
+	switch selector.Kind {
+	case ProjectSelectorKindName:
+		name, version, _ := strings.Cut(rest, ":")
+		selector.Value = name
+		selector.Version = version
+	case ProjectSelectorKindRegex:
+		regex, err := regexp.Compile(rest)
+		if err != nil {
+			return selector, fmt.Errorf("project selector %q: %w", s, err)
+		}
+		selector.Value = rest
+		selector.regex = regex
+	default:
+		selector.Value = rest
+	}
+
+	if selector.Value == "" {
+		return selector, fmt.Errorf("project selector %q: empty %s", s, selector.Kind)
+	}
+
+	return selector, nil
+}
+
+func (s ProjectSelector) String() string {
+	return s.raw
+}
+
+// Match reports whether the project is selected, regardless of Exclude.
+func (s ProjectSelector) Match(p dtrack.Project) bool {
+	switch s.Kind {
+	case ProjectSelectorKindName:
+		return p.Name == s.Value && (s.Version == "" || p.Version == s.Version)
+	case ProjectSelectorKindTag:
+		for _, t := range p.Tags {
+			if strings.EqualFold(t.Name, s.Value) {
+				return true
+			}
+		}
+		return false
+	case ProjectSelectorKindRegex:
+		return s.regex.MatchString(p.Name)
+	case ProjectSelectorKindClassifier:
+		return strings.EqualFold(p.Classifier, s.Value)
+	}
+
+	return false
+}
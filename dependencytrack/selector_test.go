@@ -0,0 +1,117 @@
+package dependencytrack
+
+import (
+	"testing"
+
+	dtrack "github.com/DependencyTrack/client-go"
+)
+
+func TestParseProjectSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    ProjectSelector
+		wantErr bool
+	}{
+		{
+			name: "name",
+			s:    "app",
+			want: ProjectSelector{Kind: ProjectSelectorKindName, Value: "app"},
+		},
+		{
+			name: "name and version",
+			s:    "app:1.0",
+			want: ProjectSelector{Kind: ProjectSelectorKindName, Value: "app", Version: "1.0"},
+		},
+		{
+			name: "explicit name",
+			s:    "name:tag:1.0",
+			want: ProjectSelector{Kind: ProjectSelectorKindName, Value: "tag", Version: "1.0"},
+		},
+		{
+			name: "tag",
+			s:    "tag:production",
+			want: ProjectSelector{Kind: ProjectSelectorKindTag, Value: "production"},
+		},
+		{
+			name: "regex",
+			s:    "regex:^payments-.*",
+			want: ProjectSelector{Kind: ProjectSelectorKindRegex, Value: "^payments-.*"},
+		},
+		{
+			name: "excluded classifier",
+			s:    "!classifier:LIBRARY",
+			want: ProjectSelector{Kind: ProjectSelectorKindClassifier, Value: "LIBRARY", Exclude: true},
+		},
+		{
+			name:    "invalid regex",
+			s:       "regex:[",
+			wantErr: true,
+		},
+		{
+			name:    "empty tag",
+			s:       "tag:",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			s:       "!",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProjectSelector(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProjectSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Kind != tt.want.Kind || got.Value != tt.want.Value || got.Version != tt.want.Version || got.Exclude != tt.want.Exclude {
+				t.Errorf("ParseProjectSelector() = %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.s {
+				t.Errorf("ProjectSelector.String() = %q, want %q", got.String(), tt.s)
+			}
+		})
+	}
+}
+
+func TestProjectSelector_Match(t *testing.T) {
+	project := dtrack.Project{
+		Name:       "payments-api",
+		Version:    "1.0",
+		Classifier: "APPLICATION",
+		Tags:       []dtrack.Tag{{Name: "production"}},
+	}
+
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{s: "payments-api", want: true},
+		{s: "payments-api:1.0", want: true},
+		{s: "payments-api:2.0", want: false},
+		{s: "payments", want: false},
+		{s: "tag:production", want: true},
+		{s: "tag:Production", want: true},
+		{s: "tag:staging", want: false},
+		{s: "regex:^payments-.*", want: true},
+		{s: "regex:^billing-", want: false},
+		{s: "classifier:application", want: true},
+		{s: "classifier:LIBRARY", want: false},
+		{s: "!tag:production", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			selector, err := ParseProjectSelector(tt.s)
+			if err != nil {
+				t.Fatalf("ParseProjectSelector() error = %v", err)
+			}
+			if got := selector.Match(project); got != tt.want {
+				t.Errorf("ProjectSelector.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
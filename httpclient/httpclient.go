@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Options configures the HTTP client returned by New.
+type Options struct {
+	// Timeout limits the time of a request including reading the response body. 0 means no timeout.
+	Timeout time.Duration
+
+	// CAFile is a PEM bundle of CA certificates trusted in addition to the system ones.
+	CAFile string
+
+	// CertFile and KeyFile are the PEM client certificate and key for mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool
+
+	// Proxy is the URL of the HTTP proxy. Empty uses the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string
+}
+
+// New returns an HTTP client configured by opts.
+func New(opts Options) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("proxy: invalid URL: %s", opts.Proxy)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy = proxy
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+	}, nil
+}
+
+func newTLSConfig(opts Options) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca file: no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("client certificate and key must be given together")
+	}
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
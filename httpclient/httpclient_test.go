@@ -0,0 +1,189 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to dir.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestNew_tls(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeClientCert(t, dir)
+
+	tests := []struct {
+		name       string
+		opts       Options
+		wantErr    bool
+		wantStatus int
+	}{
+		{
+			name:    "unknown authority",
+			opts:    Options{},
+			wantErr: true,
+		},
+		{
+			name:       "ca file",
+			opts:       Options{CAFile: caFile},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "insecure skip verify",
+			opts:       Options{InsecureSkipVerify: true},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "client certificate",
+			opts:       Options{CAFile: caFile, CertFile: certFile, KeyFile: keyFile},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := New(tt.opts)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			resp, err := client.Get(ts.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Get() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNew_proxy(t *testing.T) {
+	var gotURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	client, err := New(Options{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	resp, err := client.Get("http://dependencytrack.example.com/api/version")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if want := "http://dependencytrack.example.com/api/version"; gotURL != want {
+		t.Errorf("proxy got %q, want %q", gotURL, want)
+	}
+}
+
+func TestNew_invalidOptions(t *testing.T) {
+	dir := t.TempDir()
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyFile, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	certFile, _ := writeClientCert(t, dir)
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{
+			name: "missing ca file",
+			opts: Options{CAFile: filepath.Join(dir, "missing.pem")},
+		},
+		{
+			name: "ca file without certificates",
+			opts: Options{CAFile: emptyFile},
+		},
+		{
+			name: "client certificate without key",
+			opts: Options{CertFile: certFile},
+		},
+		{
+			name: "invalid client key",
+			opts: Options{CertFile: certFile, KeyFile: emptyFile},
+		},
+		{
+			name: "invalid proxy",
+			opts: Options{Proxy: "proxy.example.com:3128"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts); err == nil {
+				t.Errorf("New() error = nil, want error")
+			}
+		})
+	}
+}
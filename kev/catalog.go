@@ -16,3 +16,17 @@ func (c *Catalog) VulnerabilitiyIDs() []string {
 	}
 	return ids
 }
+
+// Filter returns a copy of the catalog containing only the vulnerabilities matching f.
+func (c *Catalog) Filter(f Filter) *Catalog {
+	filtered := *c
+	filtered.Vulnerabilities = []Vulnerability{}
+	for _, v := range c.Vulnerabilities {
+		if f.Match(v) {
+			filtered.Vulnerabilities = append(filtered.Vulnerabilities, v)
+		}
+	}
+	filtered.Count = len(filtered.Vulnerabilities)
+
+	return &filtered
+}
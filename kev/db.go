@@ -1,13 +1,17 @@
 package kev
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/takumakume/kev-to-dependencytrack/atomicfile"
+	"github.com/takumakume/kev-to-dependencytrack/version"
 	"k8s.io/utils/clock"
 )
 
@@ -15,6 +19,10 @@ const (
 	DEFAULT_KEV_CATALOG_JSON_URL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
 	DB_FILE_NAME                 = "kev.json"
 	DB_DOWNLOAD_AT_FILE_NAME     = "kev_downloaded_at"
+	DB_ETAG_FILE_NAME            = "kev_etag"
+	DB_LAST_MODIFIED_FILE_NAME   = "kev_last_modified"
+	DEFAULT_MAX_AGE              = 24 * time.Hour
+	DEFAULT_TIMEOUT              = time.Minute
 )
 
 type dbFetcher interface {
@@ -26,42 +34,85 @@ type dbFetcher interface {
 }
 
 type db struct {
-	url      string
-	cacheDir string
-	clock    clock.Clock
+	url        string
+	cacheDir   string
+	maxAge     time.Duration
+	httpClient *http.Client
+	userAgent  string
+	clock      clock.Clock
 }
 
 type dbOpts struct {
-	url      string
-	cacheDir string
-	clock    clock.Clock
+	url        string
+	cacheDir   string
+	maxAge     time.Duration
+	httpClient *http.Client
+	userAgent  string
+	clock      clock.Clock
 }
 
-type dbOpt func(*dbOpts)
+type Option func(*dbOpts)
 
-// func withURL(url string) dbOpt {
-// 	return func(opts *dbOpts) {
-// 		opts.url = url
-// 	}
-// }
+// WithURL sets the URL of the KEV catalog. An empty url keeps the default.
+func WithURL(url string) Option {
+	return func(opts *dbOpts) {
+		if url != "" {
+			opts.url = url
+		}
+	}
+}
 
-// func withCacheDir(cacheDir string) dbOpt {
-// 	return func(opts *dbOpts) {
-// 		opts.cacheDir = cacheDir
-// 	}
-// }
+// WithCacheDir sets the directory the KEV catalog is cached in. An empty cacheDir keeps the default.
+func WithCacheDir(cacheDir string) Option {
+	return func(opts *dbOpts) {
+		if cacheDir != "" {
+			opts.cacheDir = cacheDir
+		}
+	}
+}
+
+// WithMaxAge sets how long the cached KEV catalog is used before it is downloaded again.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(opts *dbOpts) {
+		opts.maxAge = maxAge
+	}
+}
+
+// WithHTTPClient sets the HTTP client the KEV catalog is downloaded with. A nil client keeps the default,
+// which times out after DEFAULT_TIMEOUT.
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *dbOpts) {
+		if client != nil {
+			opts.httpClient = client
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the download. An empty userAgent keeps the default.
+func WithUserAgent(userAgent string) Option {
+	return func(opts *dbOpts) {
+		if userAgent != "" {
+			opts.userAgent = userAgent
+		}
+	}
+}
 
-// func withClock(clock clock.Clock) dbOpt {
-// 	return func(opts *dbOpts) {
-// 		opts.clock = clock
-// 	}
-// }
+func withClock(clock clock.Clock) Option {
+	return func(opts *dbOpts) {
+		opts.clock = clock
+	}
+}
 
-func newDB(opts ...dbOpt) *db {
+func newDB(opts ...Option) *db {
 	o := &dbOpts{
 		url:      DEFAULT_KEV_CATALOG_JSON_URL,
 		cacheDir: filepath.Join(os.TempDir(), "kev-to-dependencytrack"),
-		clock:    clock.RealClock{},
+		maxAge:   DEFAULT_MAX_AGE,
+		httpClient: &http.Client{
+			Timeout: DEFAULT_TIMEOUT,
+		},
+		userAgent: version.Name + "/" + version.Version,
+		clock:     clock.RealClock{},
 	}
 
 	for _, opt := range opts {
@@ -69,9 +120,12 @@ func newDB(opts ...dbOpt) *db {
 	}
 
 	return &db{
-		url:      o.url,
-		cacheDir: o.cacheDir,
-		clock:    o.clock,
+		url:        o.url,
+		cacheDir:   o.cacheDir,
+		maxAge:     o.maxAge,
+		httpClient: o.httpClient,
+		userAgent:  o.userAgent,
+		clock:      o.clock,
 	}
 }
 
@@ -83,18 +137,47 @@ func (d *db) downloadAtFilePath() string {
 	return filepath.Join(d.cacheDir, DB_DOWNLOAD_AT_FILE_NAME)
 }
 
+func (d *db) etagFilePath() string {
+	return filepath.Join(d.cacheDir, DB_ETAG_FILE_NAME)
+}
+
+func (d *db) lastModifiedFilePath() string {
+	return filepath.Join(d.cacheDir, DB_LAST_MODIFIED_FILE_NAME)
+}
+
+// download fetches the KEV catalog. When the catalog is cached, the request is
+// conditional on the cached ETag and Last-Modified, and a 304 Not Modified
+// response only refreshes the download time.
 func (d *db) download() error {
-	resp, err := http.Get(d.url)
+	req, err := http.NewRequest(http.MethodGet, d.url, nil)
 	if err != nil {
 		return err
 	}
+	req.Header.Set("User-Agent", d.userAgent)
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("kev db fetch error: %s: status %s", d.url, resp.Status)
+	if _, err := os.Stat(d.dbFilePath()); err == nil {
+		if etag, err := os.ReadFile(d.etagFilePath()); err == nil && len(etag) > 0 {
+			req.Header.Set("If-None-Match", string(etag))
+		}
+		if lastModified, err := os.ReadFile(d.lastModifiedFilePath()); err == nil && len(lastModified) > 0 {
+			req.Header.Set("If-Modified-Since", string(lastModified))
+		}
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		log.Println("KEV is not modified")
 
-	if resp.Body == nil {
-		return fmt.Errorf("kev db fetch error: %s: body is nil", d.url)
+		return d.writeDownloadAt()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kev db fetch error: %s: status %s", d.url, resp.Status)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -102,22 +185,66 @@ func (d *db) download() error {
 		return err
 	}
 
+	if err := validateCatalog(body); err != nil {
+		return fmt.Errorf("kev db fetch error: %s: %w", d.url, err)
+	}
+
 	if err := os.MkdirAll(d.cacheDir, 0755); err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(d.dbFilePath(), body, 0644); err != nil {
+	if err := atomicfile.WriteFile(d.dbFilePath(), body, 0644); err != nil {
 		return err
 	}
 
-	date := d.clock.Now().Format(time.RFC3339)
-	if err := os.WriteFile(d.downloadAtFilePath(), []byte(date), 0644); err != nil {
+	if err := writeOrRemoveFile(d.etagFilePath(), resp.Header.Get("ETag")); err != nil {
 		return err
 	}
 
+	if err := writeOrRemoveFile(d.lastModifiedFilePath(), resp.Header.Get("Last-Modified")); err != nil {
+		return err
+	}
+
+	return d.writeDownloadAt()
+}
+
+func (d *db) writeDownloadAt() error {
+	date := d.clock.Now().Format(time.RFC3339)
+	return atomicfile.WriteFile(d.downloadAtFilePath(), []byte(date), 0644)
+}
+
+// validateCatalog checks that body is a plausible KEV catalog, so that a
+// truncated response or an error page is never cached.
+func validateCatalog(body []byte) error {
+	catalog := &Catalog{}
+	if err := json.Unmarshal(body, catalog); err != nil {
+		return fmt.Errorf("invalid catalog: %w", err)
+	}
+
+	if catalog.Count <= 0 {
+		return fmt.Errorf("invalid catalog: count is %d", catalog.Count)
+	}
+
+	if catalog.Count != len(catalog.Vulnerabilities) {
+		return fmt.Errorf("invalid catalog: count is %d but has %d vulnerabilities", catalog.Count, len(catalog.Vulnerabilities))
+	}
+
 	return nil
 }
 
+// writeOrRemoveFile writes content to path, or removes path when content is empty
+// so that a stale value is never sent.
+func writeOrRemoveFile(path, content string) error {
+	if content == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	return atomicfile.WriteFile(path, []byte(content), 0644)
+}
+
 func (d *db) needsUpdate() (bool, error) {
 	if _, err := os.Stat(d.dbFilePath()); err != nil {
 		return true, nil
@@ -138,7 +265,7 @@ func (d *db) needsUpdate() (bool, error) {
 		return true, nil
 	}
 
-	if d.clock.Now().Sub(t) > 24*time.Hour {
+	if d.clock.Now().Sub(t) > d.maxAge {
 		return true, nil
 	}
 
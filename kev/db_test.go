@@ -4,6 +4,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,45 +14,86 @@ import (
 	clocktesting "k8s.io/utils/clock/testing"
 )
 
-func Test_db_download(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("test data"))
-	}))
-	defer ts.Close()
-	tmpDir, err := os.MkdirTemp("", "test")
-	if err != nil {
-		t.Fatalf("failed to create temporary file: %v", err)
-	}
-	defer os.Remove(tmpDir)
+const testCatalog = `{"catalogVersion":"2019.10.01","count":1,"vulnerabilities":[{"cveID":"CVE-2019-0001"}]}`
 
-	type fields struct {
-		url      string
-		cacheDir string
-		clock    clock.Clock
-	}
+func Test_db_download(t *testing.T) {
 	tests := []struct {
-		name    string
-		fields  fields
-		wantErr bool
+		name           string
+		responseBody   string
+		cachedContent  string
+		wantErr        bool
+		wantContent    string
+		wantDownloadAt string
 	}{
 		{
-			name: "success",
-			fields: fields{
-				url:      ts.URL,
-				cacheDir: tmpDir,
-				clock:    clocktesting.NewFakeClock(time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)),
-			},
-			wantErr: false,
+			name:           "success",
+			responseBody:   testCatalog,
+			wantErr:        false,
+			wantContent:    testCatalog,
+			wantDownloadAt: "2019-10-01T00:00:00Z",
+		},
+		{
+			name:           "success overwrites cache",
+			responseBody:   testCatalog,
+			cachedContent:  "cached data",
+			wantErr:        false,
+			wantContent:    testCatalog,
+			wantDownloadAt: "2019-10-01T00:00:00Z",
+		},
+		{
+			name:          "html error page keeps cache",
+			responseBody:  "<html>error</html>",
+			cachedContent: "cached data",
+			wantErr:       true,
+			wantContent:   "cached data",
+		},
+		{
+			name:          "truncated body keeps cache",
+			responseBody:  testCatalog[:len(testCatalog)-10],
+			cachedContent: "cached data",
+			wantErr:       true,
+			wantContent:   "cached data",
+		},
+		{
+			name:          "empty catalog keeps cache",
+			responseBody:  `{"count":0,"vulnerabilities":[]}`,
+			cachedContent: "cached data",
+			wantErr:       true,
+			wantContent:   "cached data",
+		},
+		{
+			name:          "count mismatch keeps cache",
+			responseBody:  `{"count":2,"vulnerabilities":[{"cveID":"CVE-2019-0001"}]}`,
+			cachedContent: "cached data",
+			wantErr:       true,
+			wantContent:   "cached data",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.responseBody))
+			}))
+			defer ts.Close()
+			tmpDir, err := os.MkdirTemp("", "test")
+			if err != nil {
+				t.Fatalf("failed to create temporary file: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+
 			d := &db{
-				url:      tt.fields.url,
-				cacheDir: tt.fields.cacheDir,
-				clock:    tt.fields.clock,
+				url:        ts.URL,
+				cacheDir:   tmpDir,
+				httpClient: http.DefaultClient,
+				clock:      clocktesting.NewFakeClock(time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)),
+			}
+			if tt.cachedContent != "" {
+				if err := os.WriteFile(d.dbFilePath(), []byte(tt.cachedContent), 0644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
 			}
+
 			if err := d.download(); (err != nil) != tt.wantErr {
 				t.Errorf("db.download() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -58,25 +102,156 @@ func Test_db_download(t *testing.T) {
 			if err != nil {
 				t.Fatalf("failed to read file: %v", err)
 			}
-			if string(body) != "test data" {
+			if string(body) != tt.wantContent {
 				t.Errorf("unexpected file content: %s", string(body))
 			}
 
-			datetime, err := os.ReadFile(d.downloadAtFilePath())
+			if tt.wantDownloadAt != "" {
+				datetime, err := os.ReadFile(d.downloadAtFilePath())
+				if err != nil {
+					t.Fatalf("failed to read downloadAtFile: %v", err)
+				}
+				if string(datetime) != tt.wantDownloadAt {
+					t.Errorf("unexpected file content: %s", string(datetime))
+				}
+			}
+
+			entries, err := os.ReadDir(tmpDir)
 			if err != nil {
-				t.Fatalf("failed to read downloadAtFile: %v", err)
+				t.Fatalf("failed to read dir: %v", err)
+			}
+			for _, e := range entries {
+				if strings.Contains(e.Name(), ".tmp") {
+					t.Errorf("temporary file is left: %s", e.Name())
+				}
+			}
+		})
+	}
+}
+
+func Test_db_download_conditional(t *testing.T) {
+	var gotIfNoneMatch, gotIfModifiedSince string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		gotIfModifiedSince = r.Header.Get("If-Modified-Since")
+		if gotIfNoneMatch == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Tue, 01 Oct 2019 00:00:00 GMT")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(testCatalog))
+	}))
+	defer ts.Close()
+	tmpDir, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fakeClock := clocktesting.NewFakeClock(time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC))
+	d := &db{
+		url:        ts.URL,
+		cacheDir:   tmpDir,
+		httpClient: http.DefaultClient,
+		clock:      fakeClock,
+	}
+
+	if err := d.download(); err != nil {
+		t.Fatalf("db.download() error = %v", err)
+	}
+	if gotIfNoneMatch != "" || gotIfModifiedSince != "" {
+		t.Errorf("unexpected conditional headers without cache: If-None-Match=%q If-Modified-Since=%q", gotIfNoneMatch, gotIfModifiedSince)
+	}
+	etag, err := os.ReadFile(d.etagFilePath())
+	if err != nil {
+		t.Fatalf("failed to read etagFile: %v", err)
+	}
+	if string(etag) != `"v1"` {
+		t.Errorf("unexpected etagFile content: %s", string(etag))
+	}
+
+	fakeClock.SetTime(time.Date(2019, 10, 2, 0, 0, 0, 0, time.UTC))
+	if err := d.download(); err != nil {
+		t.Fatalf("db.download() error = %v", err)
+	}
+	if gotIfNoneMatch != `"v1"` || gotIfModifiedSince != "Tue, 01 Oct 2019 00:00:00 GMT" {
+		t.Errorf("unexpected conditional headers: If-None-Match=%q If-Modified-Since=%q", gotIfNoneMatch, gotIfModifiedSince)
+	}
+
+	body, err := os.ReadFile(d.dbFilePath())
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(body) != testCatalog {
+		t.Errorf("unexpected file content: %s", string(body))
+	}
+
+	datetime, err := os.ReadFile(d.downloadAtFilePath())
+	if err != nil {
+		t.Fatalf("failed to read downloadAtFile: %v", err)
+	}
+	if string(datetime) != "2019-10-02T00:00:00Z" {
+		t.Errorf("unexpected downloadAtFile content: %s", string(datetime))
+	}
+}
+
+func Test_db_download_userAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "default",
+			want: "kev-to-dependencytrack/dev",
+		},
+		{
+			name: "custom",
+			opts: []Option{WithUserAgent("test-agent/1.0")},
+			want: "test-agent/1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserAgent := make(chan string, 1)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserAgent <- r.Header.Get("User-Agent")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(testCatalog))
+			}))
+			defer ts.Close()
+
+			d := newDB(append([]Option{WithURL(ts.URL), WithCacheDir(t.TempDir())}, tt.opts...)...)
+			if err := d.download(); err != nil {
+				t.Fatalf("db.download() error = %v", err)
 			}
-			if string(datetime) != "2019-10-01T00:00:00Z" {
-				t.Errorf("unexpected file content: %s", string(datetime))
+			if got := <-gotUserAgent; got != tt.want {
+				t.Errorf("unexpected User-Agent: %q, want %q", got, tt.want)
 			}
 		})
 	}
 }
 
+func Test_db_download_httpClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// respond only after the client gave up
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	d := newDB(WithURL(ts.URL), WithCacheDir(t.TempDir()), WithHTTPClient(&http.Client{Timeout: 10 * time.Millisecond}))
+	if err := d.download(); err == nil {
+		t.Errorf("db.download() error = nil, want timeout")
+	}
+}
+
 func Test_db_needsUpdate(t *testing.T) {
 	tests := []struct {
 		name                    string
 		clock                   clock.Clock
+		maxAge                  time.Duration
 		createCacheDir          bool
 		dbFilePathContent       string
 		downloadedAtFileContent string
@@ -126,9 +301,32 @@ func Test_db_needsUpdate(t *testing.T) {
 			want:                    true,
 			wantErr:                 false,
 		},
+		{
+			name:                    "1h have not passed with maxAge 1h",
+			createCacheDir:          true,
+			maxAge:                  time.Hour,
+			dbFilePathContent:       "test data",
+			clock:                   clocktesting.NewFakeClock(time.Date(2019, 10, 1, 1, 0, 0, 0, time.UTC)),
+			downloadedAtFileContent: "2019-10-01T00:00:00Z",
+			want:                    false,
+			wantErr:                 false,
+		},
+		{
+			name:                    "after 1h+ with maxAge 1h",
+			createCacheDir:          true,
+			maxAge:                  time.Hour,
+			dbFilePathContent:       "test data",
+			clock:                   clocktesting.NewFakeClock(time.Date(2019, 10, 1, 1, 0, 0, 1, time.UTC)),
+			downloadedAtFileContent: "2019-10-01T00:00:00Z",
+			want:                    true,
+			wantErr:                 false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxAge == 0 {
+				tt.maxAge = DEFAULT_MAX_AGE
+			}
 			var tempDir string
 			var err error
 			if tt.createCacheDir {
@@ -140,6 +338,7 @@ func Test_db_needsUpdate(t *testing.T) {
 			}
 			d := &db{
 				cacheDir: tempDir,
+				maxAge:   tt.maxAge,
 				clock:    tt.clock,
 			}
 			if tt.dbFilePathContent != "" {
@@ -167,3 +366,57 @@ func Test_db_needsUpdate(t *testing.T) {
 		})
 	}
 }
+
+func Test_newDB(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name string
+		opts []Option
+		want *db
+	}{
+		{
+			name: "default",
+			opts: []Option{withClock(fakeClock)},
+			want: &db{
+				url:        DEFAULT_KEV_CATALOG_JSON_URL,
+				cacheDir:   filepath.Join(os.TempDir(), "kev-to-dependencytrack"),
+				maxAge:     DEFAULT_MAX_AGE,
+				httpClient: &http.Client{Timeout: DEFAULT_TIMEOUT},
+				userAgent:  "kev-to-dependencytrack/dev",
+				clock:      fakeClock,
+			},
+		},
+		{
+			name: "empty values keep the default",
+			opts: []Option{WithURL(""), WithCacheDir(""), WithHTTPClient(nil), WithUserAgent(""), withClock(fakeClock)},
+			want: &db{
+				url:        DEFAULT_KEV_CATALOG_JSON_URL,
+				cacheDir:   filepath.Join(os.TempDir(), "kev-to-dependencytrack"),
+				maxAge:     DEFAULT_MAX_AGE,
+				httpClient: &http.Client{Timeout: DEFAULT_TIMEOUT},
+				userAgent:  "kev-to-dependencytrack/dev",
+				clock:      fakeClock,
+			},
+		},
+		{
+			name: "with options",
+			opts: []Option{WithURL("https://example.com/kev.json"), WithCacheDir("/var/cache/kev"), WithMaxAge(time.Hour), WithHTTPClient(http.DefaultClient), WithUserAgent("test-agent/1.0"), withClock(fakeClock)},
+			want: &db{
+				url:        "https://example.com/kev.json",
+				cacheDir:   "/var/cache/kev",
+				maxAge:     time.Hour,
+				httpClient: http.DefaultClient,
+				userAgent:  "test-agent/1.0",
+				clock:      fakeClock,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newDB(tt.opts...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newDB() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
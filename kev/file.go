@@ -0,0 +1,48 @@
+package kev
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileDB reads the KEV catalog from a local file instead of downloading it.
+// The path "-" reads the catalog from stdin.
+type fileDB struct {
+	path  string
+	stdin io.Reader
+}
+
+func newFileDB(path string) *fileDB {
+	return &fileDB{
+		path:  path,
+		stdin: os.Stdin,
+	}
+}
+
+func (f *fileDB) download() error {
+	return nil
+}
+
+func (f *fileDB) needsUpdate() (bool, error) {
+	return false, nil
+}
+
+// read returns the catalog after validating it, so that an empty or truncated
+// file is rejected like a broken download.
+func (f *fileDB) read() (body []byte, err error) {
+	if f.path == "-" {
+		body, err = io.ReadAll(f.stdin)
+	} else {
+		body, err = os.ReadFile(f.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCatalog(body); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,71 @@
+package kev
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_fileDB_read(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "kev.json")
+	if err := os.WriteFile(path, []byte(testCatalog), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		f       *fileDB
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "file",
+			f:    &fileDB{path: path},
+			want: testCatalog,
+		},
+		{
+			name: "stdin",
+			f:    &fileDB{path: "-", stdin: strings.NewReader(testCatalog)},
+			want: testCatalog,
+		},
+		{
+			name:    "empty stdin",
+			f:       &fileDB{path: "-", stdin: strings.NewReader("")},
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			f:       &fileDB{path: "-", stdin: strings.NewReader(testCatalog[:len(testCatalog)/2])},
+			wantErr: true,
+		},
+		{
+			name:    "empty catalog",
+			f:       &fileDB{path: "-", stdin: strings.NewReader(`{"catalogVersion":"2019.10.01","count":0,"vulnerabilities":[]}`)},
+			wantErr: true,
+		},
+		{
+			name:    "file not found",
+			f:       &fileDB{path: filepath.Join(tmpDir, "not-found.json")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.f.read()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("fileDB.read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("fileDB.read() = %s, want %s", string(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package kev
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DATE_FORMAT is the format of the dates in the KEV catalog.
+const DATE_FORMAT = "2006-01-02"
+
+// Filter selects vulnerabilities of the catalog.
+// Zero value fields match every vulnerability.
+type Filter struct {
+	VendorProjects []string
+	Products       []string
+	DateAddedSince time.Time
+	DateAddedUntil time.Time
+	DueDateSince   time.Time
+	DueDateUntil   time.Time
+	CveID          *regexp.Regexp
+
+	// KnownRansomwareCampaignUse is "Known" or "Unknown".
+	KnownRansomwareCampaignUse string
+	// CWEs matches vulnerabilities having at least one of these CWEs.
+	CWEs []string
+}
+
+func (f Filter) Match(v Vulnerability) bool {
+	if len(f.VendorProjects) > 0 && !containsFold(f.VendorProjects, v.VendorProject) {
+		return false
+	}
+
+	if len(f.Products) > 0 && !containsFold(f.Products, v.Product) {
+		return false
+	}
+
+	if !inDateRange(v.DateAdded, f.DateAddedSince, f.DateAddedUntil) {
+		return false
+	}
+
+	if !inDateRange(v.DueDate, f.DueDateSince, f.DueDateUntil) {
+		return false
+	}
+
+	if f.CveID != nil && !f.CveID.MatchString(v.CveID) {
+		return false
+	}
+
+	if f.KnownRansomwareCampaignUse != "" && !strings.EqualFold(f.KnownRansomwareCampaignUse, v.KnownRansomwareCampaignUse) {
+		return false
+	}
+
+	if len(f.CWEs) > 0 && !containsAnyFold(f.CWEs, v.CWEs) {
+		return false
+	}
+
+	return true
+}
+
+func containsFold(ss []string, s string) bool {
+	for _, e := range ss {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAnyFold(ss, targets []string) bool {
+	for _, t := range targets {
+		if containsFold(ss, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDateRange reports whether date is between since and until inclusive.
+// A zero since or until is unbounded.
+func inDateRange(date string, since, until time.Time) bool {
+	if since.IsZero() && until.IsZero() {
+		return true
+	}
+
+	t, err := time.Parse(DATE_FORMAT, date)
+	if err != nil {
+		return false
+	}
+
+	if !since.IsZero() && t.Before(since) {
+		return false
+	}
+
+	if !until.IsZero() && t.After(until) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,139 @@
+package kev
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFilter_Match(t *testing.T) {
+	v := Vulnerability{
+		CveID:         "CVE-2023-1234",
+		VendorProject: "Microsoft",
+		Product:       "Windows",
+		DateAdded:     "2023-03-01",
+		DueDate:       "2023-03-22",
+
+		KnownRansomwareCampaignUse: "Known",
+		CWEs:                       []string{"CWE-20", "CWE-787"},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{
+			name:   "empty filter",
+			filter: Filter{},
+			want:   true,
+		},
+		{
+			name:   "vendorProject matches case insensitively",
+			filter: Filter{VendorProjects: []string{"Apple", "microsoft"}},
+			want:   true,
+		},
+		{
+			name:   "vendorProject does not match",
+			filter: Filter{VendorProjects: []string{"Apple"}},
+			want:   false,
+		},
+		{
+			name:   "product matches",
+			filter: Filter{Products: []string{"Windows"}},
+			want:   true,
+		},
+		{
+			name:   "product does not match",
+			filter: Filter{Products: []string{"Office"}},
+			want:   false,
+		},
+		{
+			name: "dateAdded in range",
+			filter: Filter{
+				DateAddedSince: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+				DateAddedUntil: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+			want: true,
+		},
+		{
+			name:   "dateAdded before since",
+			filter: Filter{DateAddedSince: time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)},
+			want:   false,
+		},
+		{
+			name:   "dateAdded after until",
+			filter: Filter{DateAddedUntil: time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC)},
+			want:   false,
+		},
+		{
+			name:   "dueDate in range",
+			filter: Filter{DueDateSince: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+			want:   true,
+		},
+		{
+			name:   "dueDate out of range",
+			filter: Filter{DueDateUntil: time.Date(2023, 3, 21, 0, 0, 0, 0, time.UTC)},
+			want:   false,
+		},
+		{
+			name:   "cveID matches",
+			filter: Filter{CveID: regexp.MustCompile(`^CVE-2023-`)},
+			want:   true,
+		},
+		{
+			name:   "cveID does not match",
+			filter: Filter{CveID: regexp.MustCompile(`^CVE-2022-`)},
+			want:   false,
+		},
+		{
+			name:   "knownRansomwareCampaignUse matches case insensitively",
+			filter: Filter{KnownRansomwareCampaignUse: "known"},
+			want:   true,
+		},
+		{
+			name:   "knownRansomwareCampaignUse does not match",
+			filter: Filter{KnownRansomwareCampaignUse: "Unknown"},
+			want:   false,
+		},
+		{
+			name:   "cwes match",
+			filter: Filter{CWEs: []string{"CWE-787", "CWE-416"}},
+			want:   true,
+		},
+		{
+			name:   "cwes do not match",
+			filter: Filter{CWEs: []string{"CWE-416"}},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(v); got != tt.want {
+				t.Errorf("Filter.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalog_Filter(t *testing.T) {
+	c := &Catalog{
+		CatalogVersion: "2023.03.01",
+		Count:          2,
+		Vulnerabilities: []Vulnerability{
+			{CveID: "CVE-2023-0001", VendorProject: "Microsoft"},
+			{CveID: "CVE-2023-0002", VendorProject: "Apple"},
+		},
+	}
+
+	got := c.Filter(Filter{VendorProjects: []string{"Apple"}})
+	if got.Count != 1 || len(got.Vulnerabilities) != 1 || got.Vulnerabilities[0].CveID != "CVE-2023-0002" {
+		t.Errorf("Catalog.Filter() = %+v", got)
+	}
+	if got.CatalogVersion != c.CatalogVersion {
+		t.Errorf("Catalog.Filter() CatalogVersion = %s, want %s", got.CatalogVersion, c.CatalogVersion)
+	}
+	if c.Count != 2 || len(c.Vulnerabilities) != 2 {
+		t.Errorf("Catalog.Filter() modified the original catalog: %+v", c)
+	}
+}
@@ -10,9 +10,17 @@ type KEV struct {
 	catalog *Catalog
 }
 
-func New() *KEV {
+func New(opts ...Option) *KEV {
 	return &KEV{
-		db: newDB(),
+		db: newDB(opts...),
+	}
+}
+
+// NewFromFile reads the KEV catalog from a local file without downloading it.
+// The path "-" reads the catalog from stdin.
+func NewFromFile(path string) *KEV {
+	return &KEV{
+		db: newFileDB(path),
 	}
 }
 
@@ -25,7 +33,7 @@ func (k *KEV) Init() error {
 	if needsUpdate {
 		log.Println("downloading KEV")
 		if err := k.db.download(); err != nil {
-			return err
+			return k.fallback(err)
 		}
 	} else {
 		log.Println("skip downloading KEV")
@@ -45,6 +53,25 @@ func (k *KEV) Init() error {
 	return nil
 }
 
+// fallback loads the previously cached catalog when downloading failed.
+// The download error is returned when there is no usable cache.
+func (k *KEV) fallback(downloadErr error) error {
+	buf, err := k.db.read()
+	if err != nil {
+		return downloadErr
+	}
+
+	catalog := &Catalog{}
+	if err := json.Unmarshal(buf, catalog); err != nil {
+		return downloadErr
+	}
+
+	log.Printf("WARN: failed to download KEV, using the cached KEV: %v", downloadErr)
+	k.catalog = catalog
+
+	return nil
+}
+
 func (k *KEV) Catalog() *Catalog {
 	return k.catalog
 }
@@ -68,9 +68,36 @@ func TestKEV_Init(t *testing.T) {
 			mockExpect: func() {
 				mockDB.EXPECT().needsUpdate().Return(true, nil)
 				mockDB.EXPECT().download().Return(errors.New("error"))
+				mockDB.EXPECT().read().Return(nil, errors.New("error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "download error with invalid cache",
+			fields: fields{
+				db:      mockDB,
+				catalog: nil,
+			},
+			mockExpect: func() {
+				mockDB.EXPECT().needsUpdate().Return(true, nil)
+				mockDB.EXPECT().download().Return(errors.New("error"))
+				mockDB.EXPECT().read().Return([]byte(`invalid json`), nil)
 			},
 			wantErr: true,
 		},
+		{
+			name: "download error falls back to cache",
+			fields: fields{
+				db:      mockDB,
+				catalog: nil,
+			},
+			mockExpect: func() {
+				mockDB.EXPECT().needsUpdate().Return(true, nil)
+				mockDB.EXPECT().download().Return(errors.New("error"))
+				mockDB.EXPECT().read().Return([]byte(`{"packages": []}`), nil)
+			},
+			wantErr: false,
+		},
 		{
 			name: "read error",
 			fields: fields{
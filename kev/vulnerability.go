@@ -1,13 +1,20 @@
 package kev
 
+const (
+	KNOWN_RANSOMWARE_CAMPAIGN_USE_KNOWN   = "Known"
+	KNOWN_RANSOMWARE_CAMPAIGN_USE_UNKNOWN = "Unknown"
+)
+
 type Vulnerability struct {
-	CveID             string `json:"cveID"`
-	VendorProject     string `json:"vendorProject"`
-	Product           string `json:"product"`
-	VulnerabilityName string `json:"vulnerabilityName"`
-	DateAdded         string `json:"dateAdded"`
-	ShortDescription  string `json:"shortDescription"`
-	RequiredAction    string `json:"requiredAction"`
-	DueDate           string `json:"dueDate"`
-	Notes             string `json:"notes"`
+	CveID                      string   `json:"cveID"`
+	VendorProject              string   `json:"vendorProject"`
+	Product                    string   `json:"product"`
+	VulnerabilityName          string   `json:"vulnerabilityName"`
+	DateAdded                  string   `json:"dateAdded"`
+	ShortDescription           string   `json:"shortDescription"`
+	RequiredAction             string   `json:"requiredAction"`
+	DueDate                    string   `json:"dueDate"`
+	KnownRansomwareCampaignUse string   `json:"knownRansomwareCampaignUse"`
+	Notes                      string   `json:"notes"`
+	CWEs                       []string `json:"cwes"`
 }
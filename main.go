@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -9,7 +10,9 @@ import (
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		if !errors.Is(err, cmd.ErrDrift) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/google/uuid"
+	"github.com/takumakume/kev-to-dependencytrack/dependencytrack"
+)
+
+// instrumentedClient counts the errors of a DependencyTrackClient by method.
+// Not found errors are expected and not counted.
+type instrumentedClient struct {
+	client dependencytrack.DependencyTrackClient
+}
+
+func InstrumentClient(client dependencytrack.DependencyTrackClient) dependencytrack.DependencyTrackClient {
+	return &instrumentedClient{client: client}
+}
+
+func observe(method string, err error) {
+	if err != nil && !dependencytrack.IsNotFound(err) {
+		ClientErrorsTotal.WithLabelValues(method).Inc()
+	}
+}
+
+func (c *instrumentedClient) GetPolicyForName(ctx context.Context, policyName string) (p dtrack.Policy, err error) {
+	p, err = c.client.GetPolicyForName(ctx, policyName)
+	observe("GetPolicyForName", err)
+	return p, err
+}
+
+func (c *instrumentedClient) CreatePolicy(ctx context.Context, policy dtrack.Policy) (p dtrack.Policy, err error) {
+	p, err = c.client.CreatePolicy(ctx, policy)
+	observe("CreatePolicy", err)
+	return p, err
+}
+
+func (c *instrumentedClient) UpdatePolicy(ctx context.Context, policy dtrack.Policy) (p dtrack.Policy, err error) {
+	p, err = c.client.UpdatePolicy(ctx, policy)
+	observe("UpdatePolicy", err)
+	return p, err
+}
+
+func (c *instrumentedClient) NeedsUpdatePolicy(current, desierd dtrack.Policy) bool {
+	return c.client.NeedsUpdatePolicy(current, desierd)
+}
+
+func (c *instrumentedClient) AddTag(ctx context.Context, policyUUID uuid.UUID, tagName string) (p dtrack.Policy, err error) {
+	p, err = c.client.AddTag(ctx, policyUUID, tagName)
+	observe("AddTag", err)
+	return p, err
+}
+
+func (c *instrumentedClient) DeleteTag(ctx context.Context, policyUUID uuid.UUID, tagName string) (p dtrack.Policy, err error) {
+	p, err = c.client.DeleteTag(ctx, policyUUID, tagName)
+	observe("DeleteTag", err)
+	return p, err
+}
+
+func (c *instrumentedClient) AddProject(ctx context.Context, policyUUID, projectUUID uuid.UUID) (p dtrack.Policy, err error) {
+	p, err = c.client.AddProject(ctx, policyUUID, projectUUID)
+	observe("AddProject", err)
+	return p, err
+}
+
+func (c *instrumentedClient) DeleteProject(ctx context.Context, policyUUID, projectUUID uuid.UUID) (p dtrack.Policy, err error) {
+	p, err = c.client.DeleteProject(ctx, policyUUID, projectUUID)
+	observe("DeleteProject", err)
+	return p, err
+}
+
+func (c *instrumentedClient) GetProjects(ctx context.Context) (pp []dtrack.Project, err error) {
+	pp, err = c.client.GetProjects(ctx)
+	observe("GetProjects", err)
+	return pp, err
+}
+
+func (c *instrumentedClient) GetProjectsForName(ctx context.Context, projectName string, excludeInactive, onlyRoot bool) (pp []dtrack.Project, err error) {
+	pp, err = c.client.GetProjectsForName(ctx, projectName, excludeInactive, onlyRoot)
+	observe("GetProjectsForName", err)
+	return pp, err
+}
+
+func (c *instrumentedClient) GetProjectForNameVersion(ctx context.Context, projectName, projectVersion string, excludeInactive, onlyRoot bool) (p dtrack.Project, err error) {
+	p, err = c.client.GetProjectForNameVersion(ctx, projectName, projectVersion, excludeInactive, onlyRoot)
+	observe("GetProjectForNameVersion", err)
+	return p, err
+}
+
+func (c *instrumentedClient) CreatePolicyCondition(ctx context.Context, policyUUID uuid.UUID, policyCondition dtrack.PolicyCondition) (p dtrack.PolicyCondition, err error) {
+	p, err = c.client.CreatePolicyCondition(ctx, policyUUID, policyCondition)
+	observe("CreatePolicyCondition", err)
+	return p, err
+}
+
+func (c *instrumentedClient) DeletePolicyCondition(ctx context.Context, policyConditionUUID uuid.UUID) (err error) {
+	err = c.client.DeletePolicyCondition(ctx, policyConditionUUID)
+	observe("DeletePolicyCondition", err)
+	return err
+}
+
+func (c *instrumentedClient) GetFindings(ctx context.Context, projectUUID uuid.UUID) (ff []dtrack.Finding, err error) {
+	ff, err = c.client.GetFindings(ctx, projectUUID)
+	observe("GetFindings", err)
+	return ff, err
+}
+
+func (c *instrumentedClient) GetAnalysis(ctx context.Context, componentUUID, projectUUID, vulnerabilityUUID uuid.UUID) (a dtrack.Analysis, err error) {
+	a, err = c.client.GetAnalysis(ctx, componentUUID, projectUUID, vulnerabilityUUID)
+	observe("GetAnalysis", err)
+	return a, err
+}
+
+func (c *instrumentedClient) RecordAnalysis(ctx context.Context, analysis dtrack.AnalysisRequest) (a dtrack.Analysis, err error) {
+	a, err = c.client.RecordAnalysis(ctx, analysis)
+	observe("RecordAnalysis", err)
+	return a, err
+}
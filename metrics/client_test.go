@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dtrack "github.com/DependencyTrack/client-go"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/takumakume/kev-to-dependencytrack/dependencytrack"
+	"github.com/takumakume/kev-to-dependencytrack/mock"
+)
+
+func TestInstrumentClient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want float64
+	}{
+		{
+			name: "success",
+			err:  nil,
+			want: 0,
+		},
+		{
+			name: "not found",
+			err:  dependencytrack.ErrPolicyNotFound,
+			want: 0,
+		},
+		{
+			name: "error",
+			err:  errors.New("error"),
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ClientErrorsTotal.Reset()
+
+			m := mock.NewMockDependencyTrackClient(ctrl)
+			m.EXPECT().GetPolicyForName(gomock.Any(), "kev").Return(dtrack.Policy{}, tt.err)
+
+			c := InstrumentClient(m)
+			if _, err := c.GetPolicyForName(context.Background(), "kev"); err != tt.err {
+				t.Errorf("GetPolicyForName() error = %v, want %v", err, tt.err)
+			}
+
+			if got := testutil.ToFloat64(ClientErrorsTotal.WithLabelValues("GetPolicyForName")); got != tt.want {
+				t.Errorf("client_errors_total = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
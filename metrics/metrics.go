@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "kev_to_dependencytrack"
+
+var (
+	KEVCatalogInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kev_catalog_info",
+		Help:      "Version of the KEV catalog of the last sync, always 1.",
+	}, []string{"catalog_version", "date_released"})
+
+	KEVCatalogCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kev_catalog_count",
+		Help:      "Number of vulnerabilities in the KEV catalog of the last sync.",
+	})
+
+	PolicyConditions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "policy_conditions",
+		Help:      "Number of policy conditions in the policy after the last sync.",
+	}, []string{"policy"})
+
+	LastSuccessTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful sync.",
+	})
+
+	ChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "changes_total",
+		Help:      "Number of changes applied to the policies by operation.",
+	}, []string{"policy", "operation"})
+
+	SyncErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_errors_total",
+		Help:      "Number of failed syncs.",
+	})
+
+	ClientErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "client_errors_total",
+		Help:      "Number of errors returned by Dependency Track by client method.",
+	}, []string{"method"})
+)
+
+// Registry holds the metrics of kev-to-dependencytrack only.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		KEVCatalogInfo,
+		KEVCatalogCount,
+		PolicyConditions,
+		LastSuccessTimestamp,
+		ChangesTotal,
+		SyncErrorsTotal,
+		ClientErrorsTotal,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// WriteToTextfile writes the metrics for the node_exporter textfile collector.
+func WriteToTextfile(path string) error {
+	return prometheus.WriteToTextfile(path, Registry)
+}
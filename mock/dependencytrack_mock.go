@@ -140,6 +140,36 @@ func (mr *MockDependencyTrackClientMockRecorder) DeleteTag(ctx, policyUUID, tagN
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTag", reflect.TypeOf((*MockDependencyTrackClient)(nil).DeleteTag), ctx, policyUUID, tagName)
 }
 
+// GetAnalysis mocks base method.
+func (m *MockDependencyTrackClient) GetAnalysis(ctx context.Context, componentUUID, projectUUID, vulnerabilityUUID uuid.UUID) (dtrack.Analysis, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAnalysis", ctx, componentUUID, projectUUID, vulnerabilityUUID)
+	ret0, _ := ret[0].(dtrack.Analysis)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAnalysis indicates an expected call of GetAnalysis.
+func (mr *MockDependencyTrackClientMockRecorder) GetAnalysis(ctx, componentUUID, projectUUID, vulnerabilityUUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnalysis", reflect.TypeOf((*MockDependencyTrackClient)(nil).GetAnalysis), ctx, componentUUID, projectUUID, vulnerabilityUUID)
+}
+
+// GetFindings mocks base method.
+func (m *MockDependencyTrackClient) GetFindings(ctx context.Context, projectUUID uuid.UUID) ([]dtrack.Finding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFindings", ctx, projectUUID)
+	ret0, _ := ret[0].([]dtrack.Finding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFindings indicates an expected call of GetFindings.
+func (mr *MockDependencyTrackClientMockRecorder) GetFindings(ctx, projectUUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFindings", reflect.TypeOf((*MockDependencyTrackClient)(nil).GetFindings), ctx, projectUUID)
+}
+
 // GetPolicyForName mocks base method.
 func (m *MockDependencyTrackClient) GetPolicyForName(ctx context.Context, policyName string) (dtrack.Policy, error) {
 	m.ctrl.T.Helper()
@@ -170,6 +200,21 @@ func (mr *MockDependencyTrackClientMockRecorder) GetProjectForNameVersion(ctx, p
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProjectForNameVersion", reflect.TypeOf((*MockDependencyTrackClient)(nil).GetProjectForNameVersion), ctx, projectName, projectVersion, excludeInactive, onlyRoot)
 }
 
+// GetProjects mocks base method.
+func (m *MockDependencyTrackClient) GetProjects(ctx context.Context) ([]dtrack.Project, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProjects", ctx)
+	ret0, _ := ret[0].([]dtrack.Project)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProjects indicates an expected call of GetProjects.
+func (mr *MockDependencyTrackClientMockRecorder) GetProjects(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProjects", reflect.TypeOf((*MockDependencyTrackClient)(nil).GetProjects), ctx)
+}
+
 // GetProjectsForName mocks base method.
 func (m *MockDependencyTrackClient) GetProjectsForName(ctx context.Context, projectName string, excludeInactive, onlyRoot bool) ([]dtrack.Project, error) {
 	m.ctrl.T.Helper()
@@ -199,6 +244,21 @@ func (mr *MockDependencyTrackClientMockRecorder) NeedsUpdatePolicy(current, desi
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NeedsUpdatePolicy", reflect.TypeOf((*MockDependencyTrackClient)(nil).NeedsUpdatePolicy), current, desierd)
 }
 
+// RecordAnalysis mocks base method.
+func (m *MockDependencyTrackClient) RecordAnalysis(ctx context.Context, analysis dtrack.AnalysisRequest) (dtrack.Analysis, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordAnalysis", ctx, analysis)
+	ret0, _ := ret[0].(dtrack.Analysis)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordAnalysis indicates an expected call of RecordAnalysis.
+func (mr *MockDependencyTrackClientMockRecorder) RecordAnalysis(ctx, analysis interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordAnalysis", reflect.TypeOf((*MockDependencyTrackClient)(nil).RecordAnalysis), ctx, analysis)
+}
+
 // UpdatePolicy mocks base method.
 func (m *MockDependencyTrackClient) UpdatePolicy(ctx context.Context, policy dtrack.Policy) (dtrack.Policy, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,204 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/takumakume/kev-to-dependencytrack/atomicfile"
+)
+
+// State records what kev-to-dependencytrack applied to Dependency Track,
+// keyed by policy name.
+type State struct {
+	// LastApplied describes the last run applying every policy successfully.
+	LastApplied *Applied `json:"lastApplied,omitempty"`
+
+	Policies map[string]*Policy `json:"policies"`
+}
+
+type Applied struct {
+	CatalogVersion string    `json:"catalogVersion"`
+	DateReleased   string    `json:"dateReleased"`
+	ConfigHash     string    `json:"configHash"`
+	AppliedAt      time.Time `json:"appliedAt"`
+}
+
+// Policy records the tags, projects and policy conditions that
+// kev-to-dependencytrack itself added to a policy, and the CVEs of the KEV
+// catalog applied by the last successful run of the policy.
+type Policy struct {
+	UUID        uuid.UUID `json:"uuid"`
+	AppliedCVEs []string  `json:"appliedCVEs"`
+
+	Tags             []string          `json:"tags"`
+	Projects         []uuid.UUID       `json:"projects"`
+	PolicyConditions []PolicyCondition `json:"policyConditions"`
+}
+
+type PolicyCondition struct {
+	Subject  string `json:"subject"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+func New() *State {
+	return &State{
+		Policies: map[string]*Policy{},
+	}
+}
+
+// Load reads the state from path. A missing file is an empty state.
+func Load(path string) (*State, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return New(), nil
+		}
+		return nil, err
+	}
+
+	s := New()
+	if err := json.Unmarshal(buf, s); err != nil {
+		return nil, err
+	}
+	if s.Policies == nil {
+		s.Policies = map[string]*Policy{}
+	}
+
+	return s, nil
+}
+
+// Save writes the state to path atomically.
+func (s *State) Save(path string) error {
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, buf, 0600)
+}
+
+// Policy returns the state of the named policy, adding an empty one when missing.
+func (s *State) Policy(name string) *Policy {
+	p, ok := s.Policies[name]
+	if !ok {
+		p = &Policy{}
+		s.Policies[name] = p
+	}
+	return p
+}
+
+// Unchanged reports whether the last successful run applied the same catalog
+// and config, and every policy in cves got the same CVEs.
+func (s *State) Unchanged(catalogVersion, dateReleased, configHash string, cves map[string][]string) bool {
+	if s.LastApplied == nil ||
+		s.LastApplied.CatalogVersion != catalogVersion ||
+		s.LastApplied.DateReleased != dateReleased ||
+		s.LastApplied.ConfigHash != configHash {
+		return false
+	}
+
+	for name, ids := range cves {
+		p, ok := s.Policies[name]
+		if !ok {
+			return false
+		}
+		if !reflect.DeepEqual(p.AppliedCVEs, sortedSet(ids)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Applied records the policy UUID and the CVEs applied by a successful run of the policy.
+func (p *Policy) Applied(policyUUID uuid.UUID, cves []string) {
+	p.UUID = policyUUID
+	p.AppliedCVEs = sortedSet(cves)
+}
+
+func (p *Policy) OwnsTag(name string) bool {
+	for _, t := range p.Tags {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) OwnsProject(projectUUID uuid.UUID) bool {
+	for _, u := range p.Projects {
+		if u == projectUUID {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) OwnsPolicyCondition(c PolicyCondition) bool {
+	for _, o := range p.PolicyConditions {
+		if o == c {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateTags forgets the removed tags and records the added ones.
+func (p *Policy) UpdateTags(removed, added []string) {
+	p.Tags = update(p.Tags, removed, added, func(a, b string) bool { return a < b })
+}
+
+// UpdateProjects forgets the removed projects and records the added ones.
+func (p *Policy) UpdateProjects(removed, added []uuid.UUID) {
+	p.Projects = update(p.Projects, removed, added, func(a, b uuid.UUID) bool { return a.String() < b.String() })
+}
+
+// UpdatePolicyConditions forgets the removed policy conditions and records the added ones.
+func (p *Policy) UpdatePolicyConditions(removed, added []PolicyCondition) {
+	p.PolicyConditions = update(p.PolicyConditions, removed, added, func(a, b PolicyCondition) bool {
+		if a.Value != b.Value {
+			return a.Value < b.Value
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.Operator < b.Operator
+	})
+}
+
+func sortedSet(ss []string) []string {
+	return update(nil, nil, ss, func(a, b string) bool { return a < b })
+}
+
+// update returns the sorted set of current without removed and with added.
+func update[T comparable](current, removed, added []T, less func(a, b T) bool) []T {
+	m := make(map[T]bool)
+	for _, o := range current {
+		m[o] = true
+	}
+	for _, o := range removed {
+		delete(m, o)
+	}
+	for _, o := range added {
+		m[o] = true
+	}
+
+	result := []T{}
+	for o := range m {
+		result = append(result, o)
+	}
+	sort.Slice(result, func(i, j int) bool { return less(result[i], result[j]) })
+
+	return result
+}
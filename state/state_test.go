@@ -0,0 +1,151 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Load(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(s, New()) {
+		t.Errorf("Load() = %+v, want empty state", s)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(invalid); err == nil {
+		t.Errorf("Load() error = nil, want error")
+	}
+}
+
+func TestState_Save(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+	projectUUID := uuid.New()
+
+	s := New()
+	p := s.Policy("kev")
+	p.UpdateTags(nil, []string{"tag1"})
+	p.UpdateProjects(nil, []uuid.UUID{projectUUID})
+	p.UpdatePolicyConditions(nil, []PolicyCondition{{Subject: "VULNERABILITY_ID", Operator: "IS", Value: "CVE-2023-0001"}})
+
+	if err := s.Save(path); err != nil {
+		t.Fatalf("State.Save() error = %v", err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("Load() = %+v, want %+v", got, s)
+	}
+}
+
+func TestPolicy_Update(t *testing.T) {
+	cond := func(v string) PolicyCondition {
+		return PolicyCondition{Subject: "VULNERABILITY_ID", Operator: "IS", Value: v}
+	}
+
+	p := &Policy{}
+	p.UpdateTags(nil, []string{"b", "a"})
+	p.UpdateTags([]string{"b"}, []string{"c", "a"})
+	if want := []string{"a", "c"}; !reflect.DeepEqual(p.Tags, want) {
+		t.Errorf("Policy.Tags = %v, want %v", p.Tags, want)
+	}
+	if !p.OwnsTag("a") || p.OwnsTag("b") {
+		t.Errorf("Policy.OwnsTag() is wrong for %v", p.Tags)
+	}
+
+	u := uuid.New()
+	p.UpdateProjects(nil, []uuid.UUID{u})
+	if !p.OwnsProject(u) || p.OwnsProject(uuid.New()) {
+		t.Errorf("Policy.OwnsProject() is wrong for %v", p.Projects)
+	}
+	p.UpdateProjects([]uuid.UUID{u}, nil)
+	if p.OwnsProject(u) {
+		t.Errorf("Policy.OwnsProject() = true after removal")
+	}
+
+	p.UpdatePolicyConditions(nil, []PolicyCondition{cond("CVE-2023-0002"), cond("CVE-2023-0001")})
+	p.UpdatePolicyConditions([]PolicyCondition{cond("CVE-2023-0002")}, nil)
+	if want := []PolicyCondition{cond("CVE-2023-0001")}; !reflect.DeepEqual(p.PolicyConditions, want) {
+		t.Errorf("Policy.PolicyConditions = %v, want %v", p.PolicyConditions, want)
+	}
+	notOwned := cond("CVE-2023-0001")
+	notOwned.Operator = "IS_NOT"
+	if !p.OwnsPolicyCondition(cond("CVE-2023-0001")) || p.OwnsPolicyCondition(notOwned) {
+		t.Errorf("Policy.OwnsPolicyCondition() is wrong for %v", p.PolicyConditions)
+	}
+}
+
+func TestState_Unchanged(t *testing.T) {
+	s := New()
+	s.LastApplied = &Applied{CatalogVersion: "2023.01.01", DateReleased: "2023-01-01", ConfigHash: "hash"}
+	s.Policy("kev").Applied(uuid.New(), []string{"CVE-2023-0002", "CVE-2023-0001"})
+
+	tests := []struct {
+		name           string
+		catalogVersion string
+		configHash     string
+		cves           map[string][]string
+		want           bool
+	}{
+		{
+			name:           "unchanged",
+			catalogVersion: "2023.01.01",
+			configHash:     "hash",
+			cves:           map[string][]string{"kev": {"CVE-2023-0001", "CVE-2023-0002"}},
+			want:           true,
+		},
+		{
+			name:           "catalog version changed",
+			catalogVersion: "2023.01.02",
+			configHash:     "hash",
+			cves:           map[string][]string{"kev": {"CVE-2023-0001", "CVE-2023-0002"}},
+			want:           false,
+		},
+		{
+			name:           "config changed",
+			catalogVersion: "2023.01.01",
+			configHash:     "other",
+			cves:           map[string][]string{"kev": {"CVE-2023-0001", "CVE-2023-0002"}},
+			want:           false,
+		},
+		{
+			name:           "cves changed",
+			catalogVersion: "2023.01.01",
+			configHash:     "hash",
+			cves:           map[string][]string{"kev": {"CVE-2023-0001"}},
+			want:           false,
+		},
+		{
+			name:           "new policy",
+			catalogVersion: "2023.01.01",
+			configHash:     "hash",
+			cves:           map[string][]string{"other": {}},
+			want:           false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Unchanged(tt.catalogVersion, "2023-01-01", tt.configHash, tt.cves); got != tt.want {
+				t.Errorf("State.Unchanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if New().Unchanged("2023.01.01", "2023-01-01", "hash", nil) {
+		t.Errorf("State.Unchanged() = true without a successful run")
+	}
+}